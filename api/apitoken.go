@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// scopeResources maps the first part of a route below /api/users/:id or
+// /api to the resource of the scope needed for it.
+var scopeResources = map[string]string{
+	"weeks":    "weeks",
+	"generate": "weeks",
+	"budget":   "weeks",
+	"recipes":  "recipes",
+	"items":    "recipes",
+	"pantry":   "pantry",
+}
+
+// RequiredScope returns the scope an API token needs for the route of the
+// request, false for routes API tokens can't be used for at all, such as
+// managing sessions and tokens.
+func RequiredScope(c echo.Context) (app.Scope, bool) {
+	path := c.Path()
+	if path == "/api/ping" {
+		return "", true
+	}
+	if strings.HasSuffix(path, "/shopping-list") {
+		return app.ScopeShoppingListRead, c.Request().Method == http.MethodGet
+	}
+	rest, ok := strings.CutPrefix(path, "/api/users/:id/")
+	if !ok {
+		rest, ok = strings.CutPrefix(path, "/api/")
+	}
+	first, _, _ := strings.Cut(rest, "/")
+	resource, known := scopeResources[first]
+	if !ok || !known {
+		return "", false
+	}
+	// Generating a week stores it as a draft.
+	if c.Request().Method == http.MethodGet && first != "generate" {
+		return app.Scope(resource + ":read"), true
+	}
+	return app.Scope(resource + ":write"), true
+}
+
+type APITokenController struct {
+	userService app.UserService
+}
+
+func NewAPITokenController(userService app.UserService) *APITokenController {
+	return &APITokenController{userService: userService}
+}
+
+func (ac *APITokenController) GetAPITokens(c echo.Context) error {
+	tokens, err := ac.userService.APITokens(currentUser(c).ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch tokens: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAPIToken creates an API token for the current user. The token is
+// only ever shown in the response.
+func (ac *APITokenController) CreateAPIToken(c echo.Context) error {
+	var newToken app.NewAPIToken
+	if err := c.Bind(&newToken); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	errors := []string{}
+	if strings.TrimSpace(newToken.Name) == "" {
+		errors = append(errors, "name is required")
+	}
+	if len(newToken.Scopes) == 0 {
+		errors = append(errors, "at least one scope is required")
+	}
+	for _, scope := range newToken.Scopes {
+		if !scope.Valid() {
+			errors = append(errors, fmt.Sprintf("scope %q need to be one of %v", scope, app.Scopes))
+		}
+	}
+	if len(errors) > 0 {
+		httpErr := app.HTTPError{
+			Message: "invalid token",
+			Code:    http.StatusUnprocessableEntity,
+			Context: app.ValidationError{Context: newToken.Name, Errors: errors},
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	secret, err := app.GenerateAPIToken()
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	token, err := ac.userService.CreateAPIToken(currentUser(c).ID.String(), &newToken, secret)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to create token: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusCreated, token)
+}
+
+func (ac *APITokenController) DeleteAPIToken(c echo.Context) error {
+	id := c.Param("id")
+	if err := ac.userService.DeleteAPIToken(currentUser(c).ID.String(), id); err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("token with id %s not found", id),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to delete token: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		scope  app.Scope
+		ok     bool
+	}{
+		{http.MethodGet, "/api/ping", "", true},
+
+		{http.MethodGet, "/api/users/:id/generate", "weeks:write", true},
+		{http.MethodPost, "/api/users/:id/weeks", "weeks:write", true},
+		{http.MethodDelete, "/api/users/:id/weeks/:weekID", "weeks:write", true},
+		{http.MethodGet, "/api/users/:id/weeks/next", "weeks:read", true},
+		{http.MethodPost, "/api/users/:id/weeks/:weekID/suggest", "weeks:write", true},
+		{http.MethodGet, "/api/users/:id/weeks/:weekID/nutrition", "weeks:read", true},
+		{http.MethodPut, "/api/users/:id/weeks/:weekID", "weeks:write", true},
+		{http.MethodPut, "/api/users/:id/weeks", "weeks:write", true},
+		{http.MethodPut, "/api/users/:id/weeks/shuffle", "weeks:write", true},
+		{http.MethodPost, "/api/users/:id/weeks/draft/accept", "weeks:write", true},
+		{http.MethodDelete, "/api/users/:id/weeks/draft", "weeks:write", true},
+		{http.MethodGet, "/api/users/:id/weeks/drafts", "weeks:read", true},
+		{http.MethodPost, "/api/users/:id/weeks/:weekID/accept", "weeks:write", true},
+		{http.MethodGet, "/api/users/:id/weeks/:year", "weeks:read", true},
+		{http.MethodGet, "/api/users/:id/weeks/last", "weeks:read", true},
+		{http.MethodDelete, "/api/users/:id/weeks/:year/all", "weeks:write", true},
+		{http.MethodGet, "/api/users/:id/budget/:year", "weeks:read", true},
+
+		{http.MethodGet, "/api/users/:id/weeks/:weekID/shopping-list", app.ScopeShoppingListRead, true},
+		{http.MethodPost, "/api/users/:id/weeks/:weekID/shopping-list", app.ScopeShoppingListRead, false},
+
+		{http.MethodPost, "/api/users/:id/recipes", "recipes:write", true},
+		{http.MethodPut, "/api/users/:id/recipes/:recipeID", "recipes:write", true},
+		{http.MethodGet, "/api/users/:id/recipes", "recipes:read", true},
+		{http.MethodGet, "/api/users/:id/recipes/stats", "recipes:read", true},
+		{http.MethodGet, "/api/users/:id/recipes/:recipeID/stats", "recipes:read", true},
+		{http.MethodGet, "/api/recipes", "recipes:read", true},
+		{http.MethodDelete, "/api/recipes", "recipes:write", true},
+		{http.MethodPut, "/api/recipes", "recipes:write", true},
+		{http.MethodGet, "/api/items", "recipes:read", true},
+
+		{http.MethodGet, "/api/users/:id/pantry", "pantry:read", true},
+		{http.MethodPost, "/api/users/:id/pantry", "pantry:write", true},
+		{http.MethodPut, "/api/users/:id/pantry/:itemID", "pantry:write", true},
+		{http.MethodDelete, "/api/users/:id/pantry/:itemID", "pantry:write", true},
+
+		// Sessions, tokens, the account, settings and households can only
+		// be managed when logged in.
+		{http.MethodGet, "/api/sessions", "", false},
+		{http.MethodDelete, "/api/sessions/:id", "", false},
+		{http.MethodGet, "/api/me", "", false},
+		{http.MethodPatch, "/api/me", "", false},
+		{http.MethodDelete, "/api/me", "", false},
+		{http.MethodPost, "/api/me/password", "", false},
+		{http.MethodGet, "/api/tokens", "", false},
+		{http.MethodPost, "/api/tokens", "", false},
+		{http.MethodDelete, "/api/tokens/:id", "", false},
+		{http.MethodGet, "/api/users/:id/settings", "", false},
+		{http.MethodPut, "/api/users/:id/settings", "", false},
+		{http.MethodGet, "/api/users/:id/diet", "", false},
+		{http.MethodPut, "/api/users/:id/diet", "", false},
+		{http.MethodGet, "/api/users/:id/household", "", false},
+		{http.MethodPost, "/api/users/:id/household/members", "", false},
+		{http.MethodPut, "/api/users/:id/household/members/:memberID", "", false},
+		{http.MethodDelete, "/api/users/:id/household/members/:memberID", "", false},
+		{http.MethodGet, "/api/users/:id/household/invites", "", false},
+		{http.MethodPost, "/api/users/:id/household/invites/:householdID/accept", "", false},
+		{http.MethodDelete, "/api/users/:id/household/invites/:householdID", "", false},
+		{http.MethodGet, "/api/admin/ping", "", false},
+		{http.MethodGet, "/api/admin/users", "", false},
+		{http.MethodPost, "/api/admin/users", "", false},
+		{http.MethodDelete, "/api/admin/users/:id", "", false},
+		{http.MethodPut, "/api/admin/users/:id/role", "", false},
+
+		// Routes added without a scope are denied by default.
+		{http.MethodGet, "/api/users/:id/export", "", false},
+		{http.MethodGet, "/api/export", "", false},
+		{http.MethodGet, "/other", "", false},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		c := e.NewContext(httptest.NewRequest(tt.method, "/", nil), httptest.NewRecorder())
+		c.SetPath(tt.path)
+		scope, ok := RequiredScope(c)
+		if ok != tt.ok || (ok && scope != tt.scope) {
+			t.Errorf("Expected %s %s to need %q, %v, got %q, %v", tt.method, tt.path, tt.scope, tt.ok, scope, ok)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+const currentUserKey = "current_user"
+
+// SetCurrentUser stores the user a request is authenticated as, for the
+// authorization middlewares and handlers to check against.
+func SetCurrentUser(c echo.Context, user *app.User) {
+	c.Set(currentUserKey, user)
+}
+
+func currentUser(c echo.Context) *app.User {
+	user, _ := c.Get(currentUserKey).(*app.User)
+	return user
+}
+
+// RequireAdmin only lets admins through.
+func RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if user := currentUser(c); user == nil || !user.IsAdmin() {
+			httpErr := app.HTTPError{
+				Message: "access denied: admin role required",
+				Code:    http.StatusForbidden,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		return next(c)
+	}
+}
+
+// RequireOwnUser only lets users through to the /api/users/:id routes of
+// their own id, admins may act on behalf of every user.
+func RequireOwnUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !strings.HasPrefix(c.Path(), "/api/users/:id") {
+			return next(c)
+		}
+		user := currentUser(c)
+		if user == nil || (user.ID.String() != c.Param("id") && !user.IsAdmin()) {
+			httpErr := app.HTTPError{
+				Message: "access denied: not allowed to access another user",
+				Code:    http.StatusForbidden,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		return next(c)
+	}
+}
+
+const currentSessionKey = "current_session"
+
+// SetCurrentSession stores the session a request is authenticated by.
+func SetCurrentSession(c echo.Context, session *app.Session) {
+	c.Set(currentSessionKey, session)
+}
+
+func currentSession(c echo.Context) *app.Session {
+	session, _ := c.Get(currentSessionKey).(*app.Session)
+	return session
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// authServer routes like main does, authenticating each request as the
+// user the test passes along.
+func authServer(user *app.User) *echo.Echo {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	authenticate := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user != nil {
+				SetCurrentUser(c, user)
+			}
+			return next(c)
+		}
+	}
+
+	api := e.Group("/api")
+	api.Use(authenticate)
+	api.Use(RequireOwnUser)
+	api.GET("/me", ok)
+	api.GET("/users/:id/weeks/:year", ok)
+	api.PUT("/users/:id/recipes/:recipeID", ok)
+	api.PUT("/recipes", ok, RequireAdmin)
+
+	admin := e.Group("/api/admin")
+	admin.Use(authenticate)
+	admin.Use(RequireAdmin)
+	admin.GET("/users", ok)
+	admin.DELETE("/users/:id", ok)
+	return e
+}
+
+func TestRequireOwnUserAndAdmin(t *testing.T) {
+	alice := &app.User{Entity: app.Entity{ID: uuid.New()}, Role: app.UserRoleUser}
+	bob := &app.User{Entity: app.Entity{ID: uuid.New()}, Role: app.UserRoleUser}
+	admin := &app.User{Entity: app.Entity{ID: uuid.New()}, Role: app.UserRoleAdmin}
+
+	tests := []struct {
+		name   string
+		user   *app.User
+		method string
+		path   string
+		code   int
+	}{
+		{"own user", alice, http.MethodGet, "/api/users/" + alice.ID.String() + "/weeks/2024", http.StatusOK},
+		{"another user", alice, http.MethodGet, "/api/users/" + bob.ID.String() + "/weeks/2024", http.StatusForbidden},
+		{"another user's recipe", alice, http.MethodPut, "/api/users/" + bob.ID.String() + "/recipes/" + uuid.NewString(), http.StatusForbidden},
+		{"admin for another user", admin, http.MethodGet, "/api/users/" + bob.ID.String() + "/weeks/2024", http.StatusOK},
+		{"unauthenticated user route", nil, http.MethodGet, "/api/users/" + alice.ID.String() + "/weeks/2024", http.StatusForbidden},
+		{"route outside users", alice, http.MethodGet, "/api/me", http.StatusOK},
+		{"non-admin on admin route", alice, http.MethodGet, "/api/admin/users", http.StatusForbidden},
+		{"non-admin deleting a user", alice, http.MethodDelete, "/api/admin/users/" + bob.ID.String(), http.StatusForbidden},
+		{"non-admin on shared recipes", alice, http.MethodPut, "/api/recipes", http.StatusForbidden},
+		{"unauthenticated admin route", nil, http.MethodGet, "/api/admin/users", http.StatusForbidden},
+		{"admin on admin route", admin, http.MethodGet, "/api/admin/users", http.StatusOK},
+		{"admin on shared recipes", admin, http.MethodPut, "/api/recipes", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			authServer(tt.user).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.code {
+				t.Errorf("Expected %d for %s %s, got %d: %s", tt.code, tt.method, tt.path, rec.Code, rec.Body)
+			}
+		})
+	}
+}
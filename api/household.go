@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// personalRoutes are the routes below /api/users/:id about the user rather
+// than what their household owns, open to members of every role.
+var personalRoutes = []string{"/settings", "/diet", "/household"}
+
+// HouseholdMiddleware resolves the household of the user in the :id path
+// parameter for the handlers to use through householdID. Viewers may only
+// read what the household owns.
+func HouseholdMiddleware(householdService app.HouseholdService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID := c.Param("id")
+			if userID == "" || !strings.HasPrefix(c.Path(), "/api/users/:id") {
+				return next(c)
+			}
+			member, err := householdService.Membership(userID)
+			if err != nil {
+				if strings.Contains(err.Error(), "no rows in result set") {
+					httpErr := app.HTTPError{
+						Message: fmt.Sprintf("user with id %s not found", userID),
+						Code:    http.StatusNotFound,
+					}
+					return c.JSON(httpErr.Code, httpErr)
+				}
+				httpErr := app.HTTPError{
+					Message: "failed to fetch household: " + err.Error(),
+					Code:    http.StatusInternalServerError,
+				}
+				return c.JSON(httpErr.Code, httpErr)
+			}
+			c.Set("member", member)
+			if !member.Role.CanEdit() && !isPersonalRoute(c.Path()) &&
+				(c.Request().Method != http.MethodGet || strings.HasSuffix(c.Path(), "/generate")) {
+				httpErr := app.HTTPError{
+					Message: fmt.Sprintf("a %s can not change what the household owns", member.Role),
+					Code:    http.StatusForbidden,
+				}
+				return c.JSON(httpErr.Code, httpErr)
+			}
+			return next(c)
+		}
+	}
+}
+
+func isPersonalRoute(path string) bool {
+	rest := strings.TrimPrefix(path, "/api/users/:id")
+	for _, route := range personalRoutes {
+		if rest == route || strings.HasPrefix(rest, route+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// householdMember returns the membership HouseholdMiddleware resolved.
+func householdMember(c echo.Context) *app.Member {
+	member, _ := c.Get("member").(*app.Member)
+	return member
+}
+
+// householdID returns the id of the household of the user in the :id path
+// parameter, which owns the recipes, weeks and pantry the request is about.
+func householdID(c echo.Context) string {
+	if member := householdMember(c); member != nil {
+		return member.HouseholdID.String()
+	}
+	return ""
+}
+
+type HouseholdController struct {
+	householdService app.HouseholdService
+	userService      app.UserService
+}
+
+func NewHouseholdController(householdService app.HouseholdService, userService app.UserService) *HouseholdController {
+	return &HouseholdController{householdService: householdService, userService: userService}
+}
+
+func (hc *HouseholdController) GetHousehold(c echo.Context) error {
+	household, err := hc.householdService.Household(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch household: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, household)
+}
+
+// InviteMember invites a user by username to join the household. They
+// become a member once they accept the invite.
+func (hc *HouseholdController) InviteMember(c echo.Context) error {
+	if httpErr := requireOwner(c); httpErr != nil {
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	var newMember struct {
+		Username string            `json:"username"`
+		Role     app.HouseholdRole `json:"role"`
+	}
+	if err := c.Bind(&newMember); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if newMember.Role == "" {
+		newMember.Role = app.RoleEditor
+	}
+	if !newMember.Role.Valid() {
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("role need to be one of %v", app.HouseholdRoles),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	user, err := hc.userService.UserByUserName(newMember.Username)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user %s not found", newMember.Username),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	invite, err := hc.householdService.InviteMember(householdID(c), user.ID.String(), newMember.Role)
+	if err != nil {
+		httpErr := memberError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusCreated, invite)
+}
+
+// GetInvites lists the invites of the user to join other households.
+func (hc *HouseholdController) GetInvites(c echo.Context) error {
+	invites, err := hc.householdService.Invites(c.Param("id"))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch invites: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, invites)
+}
+
+// AcceptInvite moves the user into the household that invited them.
+func (hc *HouseholdController) AcceptInvite(c echo.Context) error {
+	member, err := hc.householdService.AcceptInvite(c.Param("householdID"), c.Param("id"))
+	if err != nil {
+		httpErr := memberError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, member)
+}
+
+func (hc *HouseholdController) DeclineInvite(c echo.Context) error {
+	if err := hc.householdService.DeclineInvite(c.Param("householdID"), c.Param("id")); err != nil {
+		httpErr := memberError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (hc *HouseholdController) UpdateMember(c echo.Context) error {
+	if httpErr := requireOwner(c); httpErr != nil {
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	var update struct {
+		Role app.HouseholdRole `json:"role"`
+	}
+	if err := c.Bind(&update); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if !update.Role.Valid() {
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("role need to be one of %v", app.HouseholdRoles),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	member, err := hc.householdService.UpdateMember(householdID(c), c.Param("memberID"), update.Role)
+	if err != nil {
+		httpErr := memberError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member from the household, which owners may do
+// for anyone and other members for themselves.
+func (hc *HouseholdController) RemoveMember(c echo.Context) error {
+	if householdMember(c).UserID.String() != c.Param("memberID") {
+		if httpErr := requireOwner(c); httpErr != nil {
+			return c.JSON(httpErr.Code, httpErr)
+		}
+	}
+	if err := hc.householdService.RemoveMember(householdID(c), c.Param("memberID")); err != nil {
+		httpErr := memberError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func requireOwner(c echo.Context) *app.HTTPError {
+	if member := householdMember(c); member == nil || member.Role != app.RoleOwner {
+		return &app.HTTPError{
+			Message: "only owners can manage the members of the household",
+			Code:    http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+func memberError(err error) app.HTTPError {
+	switch {
+	case errors.Is(err, app.ErrNotMember), errors.Is(err, app.ErrNoInvite):
+		return app.HTTPError{Message: err.Error(), Code: http.StatusNotFound}
+	case errors.Is(err, app.ErrLastOwner), errors.Is(err, app.ErrLastMember), errors.Is(err, app.ErrAlreadyMember):
+		return app.HTTPError{Message: err.Error(), Code: http.StatusConflict}
+	}
+	return app.HTTPError{Message: "Error: " + err.Error(), Code: http.StatusInternalServerError}
+}
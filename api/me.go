@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// GetMe returns the current user.
+func (uc *UserController) GetMe(c echo.Context) error {
+	return c.JSON(http.StatusOK, currentUser(c))
+}
+
+// UpdateMe changes the name or username of the current user.
+func (uc *UserController) UpdateMe(c echo.Context) error {
+	var update app.UserUpdate
+	if err := c.Bind(&update); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	errs := []string{}
+	if update.Name != nil && strings.TrimSpace(*update.Name) == "" {
+		errs = append(errs, "name can not be empty")
+	}
+	if update.Username != nil && strings.TrimSpace(*update.Username) == "" {
+		errs = append(errs, "username can not be empty")
+	}
+	if len(errs) > 0 {
+		httpErr := app.HTTPError{
+			Message: "invalid user",
+			Code:    http.StatusUnprocessableEntity,
+			Context: app.ValidationError{Context: currentUser(c).Username, Errors: errs},
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	user, err := uc.userService.UpdateUser(currentUser(c).ID.String(), &update)
+	if err != nil {
+		if errors.Is(err, app.ErrUsernameTaken) {
+			httpErr := app.HTTPError{
+				Message: err.Error(),
+				Code:    http.StatusConflict,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to update user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets a new password for the current user once the old one
+// checks out. The user stays logged in on this device only, and their API
+// tokens are revoked.
+func (uc *UserController) ChangePassword(c echo.Context) error {
+	var change struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.Bind(&change); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if change.NewPassword == "" {
+		httpErr := app.HTTPError{
+			Message: "new_password is required",
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	user := currentUser(c)
+	if httpErr := uc.verifyPassword(user, change.OldPassword); httpErr != nil {
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	newHash, err := bcrypt.GenerateFromPassword([]byte(change.NewPassword), 14)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to hash password",
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	var keep string
+	if session := currentSession(c); session != nil {
+		keep = session.ID.String()
+	}
+	if err := uc.userService.SetUserPassword(user.ID.String(), newHash, keep); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to change password: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteMe deletes the account of the current user, confirmed by their
+// password, together with everything only they own.
+func (uc *UserController) DeleteMe(c echo.Context) error {
+	var confirm struct {
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&confirm); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	user := currentUser(c)
+	if httpErr := uc.verifyPassword(user, confirm.Password); httpErr != nil {
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err := uc.userService.DeleteUser(user.ID.String()); err != nil {
+		if errors.Is(err, app.ErrLastOwner) {
+			httpErr := app.HTTPError{
+				Message: "make another member owner of the household first: " + err.Error(),
+				Code:    http.StatusConflict,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to delete user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (uc *UserController) verifyPassword(user *app.User, password string) *app.HTTPError {
+	hash, err := uc.userService.GetUserHash(user.Username)
+	if err != nil {
+		return &app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return &app.HTTPError{
+			Message: "invalid password",
+			Code:    http.StatusForbidden,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+type PantryController struct {
+	pantryService app.PantryService
+}
+
+func NewPantryController(pantryService app.PantryService) *PantryController {
+	return &PantryController{pantryService: pantryService}
+}
+
+func (pc *PantryController) GetPantry(c echo.Context) error {
+	items, err := pc.pantryService.PantryItems(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch pantry: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (pc *PantryController) CreatePantryItem(c echo.Context) error {
+	var newItem app.NewPantryItem
+	if err := c.Bind(&newItem); err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if errors := validatePantryItem(&newItem); len(errors) > 0 {
+		httpErr := app.HTTPError{
+			Message: "pantry item validation failed",
+			Code:    http.StatusUnprocessableEntity,
+			Context: app.ValidationError{
+				Context: "pantry item",
+				Errors:  errors,
+			},
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	item, err := pc.pantryService.CreatePantryItem(&newItem, householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to save pantry item: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusCreated, item)
+}
+
+func (pc *PantryController) UpdatePantryItem(c echo.Context) error {
+	var item app.PantryItem
+	if err := c.Bind(&item); err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	id, err := uuid.Parse(c.Param("itemID"))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("pantry item with id %s not found", c.Param("itemID")),
+			Code:    http.StatusNotFound,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	item.ID = id
+	if errors := validatePantryItem(&item.NewPantryItem); len(errors) > 0 {
+		httpErr := app.HTTPError{
+			Message: "pantry item validation failed",
+			Code:    http.StatusUnprocessableEntity,
+			Context: app.ValidationError{
+				Context: "pantry item",
+				Errors:  errors,
+			},
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	updated, err := pc.pantryService.UpdatePantryItem(&item, householdID(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("pantry item with id %s not found", id),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to save pantry item: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, updated)
+}
+
+func (pc *PantryController) DeletePantryItem(c echo.Context) error {
+	itemID := c.Param("itemID")
+	if err := pc.pantryService.DeletePantryItem(itemID, householdID(c)); err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("pantry item with id %s not found", itemID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to delete pantry item: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func validatePantryItem(item *app.NewPantryItem) []string {
+	errors := []string{}
+	if strings.TrimSpace(item.Name) == "" {
+		errors = append(errors, "name is required")
+	}
+	if item.Amount <= 0 {
+		errors = append(errors, "amount need to be more than 0")
+	}
+	return errors
+}
@@ -1,19 +1,23 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 
 	"nrdev.se/mealshuffler/app"
 )
 
 type RecipeController struct {
-	recipeService app.RecipeService
+	recipeService   app.RecipeService
+	nutrientService app.NutrientService
 }
 
-func NewRecipeController(recipeService app.RecipeService) *RecipeController {
-	return &RecipeController{recipeService: recipeService}
+func NewRecipeController(recipeService app.RecipeService, nutrientService app.NutrientService) *RecipeController {
+	return &RecipeController{recipeService: recipeService, nutrientService: nutrientService}
 }
 
 func (rc *RecipeController) GetRecipes(c echo.Context) error {
@@ -28,7 +32,23 @@ func (rc *RecipeController) GetRecipes(c echo.Context) error {
 	return c.JSON(http.StatusOK, recipes)
 }
 func (rc *RecipeController) GetUserRecipes(c echo.Context) error {
-	recipes, err := rc.recipeService.UserRecipes(c.Param("id"))
+	filter, err := parseTagFilter(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	recipes, err := rc.recipeService.HouseholdRecipes(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	foods, err := rc.nutrientService.Foods()
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "Error: " + err.Error(),
@@ -36,6 +56,11 @@ func (rc *RecipeController) GetUserRecipes(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
+	table := app.NewNutrientTable(foods)
+	recipes = filter.Apply(recipes)
+	for _, recipe := range recipes {
+		recipe.Nutrition = recipe.PortionNutrition(table)
+	}
 	return c.JSON(http.StatusOK, recipes)
 }
 
@@ -44,37 +69,14 @@ func (rc *RecipeController) CreateRecipe(c echo.Context) error {
 	if err := c.Bind(&newRecipe); err != nil {
 		return c.String(http.StatusBadRequest, "Error: "+err.Error())
 	}
-	if newRecipe.Name == "" {
-		httpErr := app.HTTPError{
-			Message: "name is required",
-			Code:    http.StatusUnprocessableEntity,
-		}
-		return c.JSON(http.StatusUnprocessableEntity, httpErr)
-	}
-	if newRecipe.ProbabilityWeight == 0 {
-		httpErr := app.HTTPError{
-			Message: "probability_weight is required",
-			Code:    http.StatusUnprocessableEntity,
-		}
-		return c.JSON(http.StatusUnprocessableEntity, httpErr)
-	}
-	if newRecipe.Portions == 0 {
+	if message := validateRecipe(&newRecipe); message != "" {
 		httpErr := app.HTTPError{
-			Message: "portions is required",
+			Message: message,
 			Code:    http.StatusUnprocessableEntity,
 		}
 		return c.JSON(http.StatusUnprocessableEntity, httpErr)
 	}
-	userID := c.Param("id")
-	if userID == "" {
-		httpErr := app.HTTPError{
-			Message: "userID is required",
-			Code:    http.StatusUnprocessableEntity,
-		}
-		return c.JSON(httpErr.Code, httpErr)
-	}
-
-	recipe, err := rc.recipeService.CreateRecipe(&newRecipe, userID)
+	recipe, err := rc.recipeService.CreateRecipe(&newRecipe, householdID(c))
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "Error: " + err.Error(),
@@ -98,6 +100,9 @@ func (rc *RecipeController) DeleteRecipes(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+// UpdateRecipe updates a recipe of the household at
+// /users/:id/recipes/:recipeID, or a shared recipe at /recipes, which only
+// admins may do, with the id in the body.
 func (rc *RecipeController) UpdateRecipe(c echo.Context) error {
 	var recipe app.Recipe
 	if err := c.Bind(&recipe); err != nil {
@@ -107,34 +112,87 @@ func (rc *RecipeController) UpdateRecipe(c echo.Context) error {
 		}
 		return c.JSON(http.StatusBadRequest, httpErr)
 	}
-	if recipe.Name == "" {
+	if recipeID := c.Param("recipeID"); recipeID != "" {
+		id, err := uuid.Parse(recipeID)
+		if err != nil {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("Error: invalid recipe id %q", recipeID),
+				Code:    http.StatusBadRequest,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		recipe.ID = id
+	}
+	if message := validateRecipe(&recipe.NewRecipe); message != "" {
 		httpErr := app.HTTPError{
-			Message: "Error: name is required",
+			Message: message,
 			Code:    http.StatusUnprocessableEntity,
 		}
 		return c.JSON(http.StatusUnprocessableEntity, httpErr)
 	}
-	if recipe.ProbabilityWeight == 0 {
+	updatedRecipe, err := rc.recipeService.UpdateRecipe(&recipe, householdID(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("Error: recipe %s not found", recipe.ID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
 		httpErr := app.HTTPError{
-			Message: "Error: probability_weight is required",
-			Code:    http.StatusUnprocessableEntity,
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusInternalServerError,
 		}
-		return c.JSON(http.StatusUnprocessableEntity, httpErr)
+		return c.JSON(http.StatusInternalServerError, httpErr)
+	}
+	return c.JSON(http.StatusOK, updatedRecipe)
+}
+
+// validateRecipe returns what is wrong with a recipe to be saved, an empty
+// string if nothing is.
+func validateRecipe(recipe *app.NewRecipe) string {
+	if recipe.Name == "" {
+		return "name is required"
+	}
+	if recipe.ProbabilityWeight == 0 {
+		return "probability_weight is required"
 	}
 	if recipe.Portions == 0 {
-		httpErr := app.HTTPError{
-			Message: "Error: portions is required",
-			Code:    http.StatusUnprocessableEntity,
+		return "portions is required"
+	}
+	for _, slot := range recipe.Slots {
+		if !slot.Valid() {
+			return fmt.Sprintf("slots can only contain %v", app.MealSlots)
 		}
-		return c.JSON(http.StatusUnprocessableEntity, httpErr)
 	}
-	updatedRecipe, err := rc.recipeService.UpdateRecipe(&recipe)
+	if m := recipe.Macros; m != nil && (m.Kcal < 0 || m.Protein < 0 || m.Fat < 0 || m.Carbs < 0 || m.Fibre < 0 || m.Salt < 0) {
+		return "macros can not be negative"
+	}
+	for _, item := range recipe.Items {
+		if strings.TrimSpace(item.Name) == "" {
+			return "item name is required"
+		}
+		if item.Amount < 0 || item.Price < 0 {
+			return fmt.Sprintf("item %s can not have a negative amount or price", item.Name)
+		}
+		for _, allergen := range item.Allergens {
+			if !allergen.Valid() {
+				return fmt.Sprintf("item %s can only have allergens %v", item.Name, app.Allergens)
+			}
+		}
+	}
+	return ""
+}
+
+// GetItems returns the catalogue of ingredients.
+func (rc *RecipeController) GetItems(c echo.Context) error {
+	items, err := rc.recipeService.Items()
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "Error: " + err.Error(),
 			Code:    http.StatusInternalServerError,
 		}
-		return c.JSON(http.StatusInternalServerError, httpErr)
+		return c.JSON(httpErr.Code, httpErr)
 	}
-	return c.JSON(http.StatusOK, updatedRecipe)
+	return c.JSON(http.StatusOK, items)
 }
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+type SessionController struct {
+	userService app.UserService
+}
+
+func NewSessionController(userService app.UserService) *SessionController {
+	return &SessionController{userService: userService}
+}
+
+// GetSessions lists the devices the current user is logged in on.
+func (sc *SessionController) GetSessions(c echo.Context) error {
+	sessions, err := sc.userService.Sessions(currentUser(c).ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch sessions: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if current := currentSession(c); current != nil {
+		for _, session := range sessions {
+			session.Current = session.ID == current.ID
+		}
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// DeleteSession logs the current user out on another device, or this one.
+func (sc *SessionController) DeleteSession(c echo.Context) error {
+	id := c.Param("id")
+	if err := sc.userService.DeleteSession(currentUser(c).ID.String(), id); err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("session with id %s not found", id),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to delete session: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
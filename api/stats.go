@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// GetRecipeStats returns how often and when each recipe of the household
+// is eaten.
+func (uc *UserController) GetRecipeStats(c echo.Context) error {
+	stats, err := uc.weekService.RecipeStats(householdID(c), time.Now())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch recipe stats: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+// GetRecipeStat returns how often and when the recipe recipeID is eaten.
+func (uc *UserController) GetRecipeStat(c echo.Context) error {
+	recipeID := c.Param("recipeID")
+	stats, err := uc.weekService.RecipeStat(recipeID, householdID(c), time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("recipe with id %s not found", recipeID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch recipe stats: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, stats)
+}
@@ -1,29 +1,39 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/snabb/isoweek"
 	"golang.org/x/crypto/bcrypt"
 
 	"nrdev.se/mealshuffler/app"
 )
 
 type UserController struct {
-	userService   app.UserService
-	recipeService app.RecipeService
-	weekService   app.WeekService
+	userService      app.UserService
+	recipeService    app.RecipeService
+	weekService      app.WeekService
+	pantryService    app.PantryService
+	nutrientService  app.NutrientService
+	householdService app.HouseholdService
 }
 
-func NewUserController(userService app.UserService, recipeService app.RecipeService, weekService app.WeekService) *UserController {
+func NewUserController(userService app.UserService, recipeService app.RecipeService, weekService app.WeekService, pantryService app.PantryService, nutrientService app.NutrientService, householdService app.HouseholdService) *UserController {
 	return &UserController{
-		userService:   userService,
-		recipeService: recipeService,
-		weekService:   weekService,
+		userService:      userService,
+		recipeService:    recipeService,
+		weekService:      weekService,
+		pantryService:    pantryService,
+		nutrientService:  nutrientService,
+		householdService: householdService,
 	}
 }
 
@@ -136,6 +146,13 @@ func (uc *UserController) DeleteUser(c echo.Context) error {
 	id := c.Param("id")
 	err = uc.userService.DeleteUser(id)
 	if err != nil {
+		if errors.Is(err, app.ErrLastOwner) {
+			httpErr := app.HTTPError{
+				Message: err.Error(),
+				Code:    http.StatusConflict,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
 		if strings.Contains(err.Error(), "failed to delete user") {
 			httpErr := app.HTTPError{
 				Message: err.Error(),
@@ -153,6 +170,50 @@ func (uc *UserController) DeleteUser(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+// UpdateUserRole makes a user an admin, or takes the admin role away.
+func (uc *UserController) UpdateUserRole(c echo.Context) error {
+	var update struct {
+		Role app.UserRole `json:"role"`
+	}
+	if err := c.Bind(&update); err != nil {
+		httpErr := app.HTTPError{
+			Message: "Error: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if !update.Role.Valid() {
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("role need to be one of %v", app.UserRoles),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err := uc.userService.SetUserRole(c.Param("id"), update.Role); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	user, err := uc.userService.User(c.Param("id"))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
 func (uc *UserController) GenerateWeek(c echo.Context) error {
 	user, err := getUser(uc, c)
 	if err != nil {
@@ -169,26 +230,27 @@ func (uc *UserController) GenerateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	weekNumber := -1
-	now := time.Now()
-	currentYear, backupWeek := now.ISOWeek()
-	weekNumber, err = uc.weekService.NextWeekNumber(user.ID.String())
+	currentYear, weekNumber, err := uc.weekService.NextWeek(householdID(c), time.Now())
 	if err != nil {
-		if strings.Contains(err.Error(), "converting NULL to int is unsupported") {
-			weekNumber = backupWeek
-		} else {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	weekCount := 1
+	if weeksStr := c.QueryParam("weeks"); weeksStr != "" {
+		weekCount, err = strconv.Atoi(weeksStr)
+		if err != nil || weekCount < 1 || weekCount > 52 {
 			httpErr := app.HTTPError{
-				Message: err.Error(),
-				Code:    http.StatusInternalServerError,
+				Message: "weeks need to be a count from 1 to 52",
+				Code:    http.StatusBadRequest,
 			}
 			return c.JSON(httpErr.Code, httpErr)
 		}
 	}
-	if weekNumber == 0 {
-		weekNumber = backupWeek
-	}
 
-	previousWeeks, err := uc.weekService.Weeks(user.ID.String(), currentYear)
+	settings, err := uc.userService.PlannerSettings(user.ID.String())
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: err.Error(),
@@ -196,14 +258,17 @@ func (uc *UserController) GenerateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	var prevDays []*app.Day
-	for _, week := range previousWeeks {
-		if week.Number == weekNumber-1 {
-			prevDays = week.Days
+	start := isoweek.StartTime(currentYear, weekNumber, time.UTC)
+	prevDays, err := uc.history(householdID(c), start, settings.HorizonDays)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
 		}
+		return c.JSON(httpErr.Code, httpErr)
 	}
 
-	recipes, err := uc.recipeService.UserRecipes(user.ID.String())
+	recipes, err := uc.recipeService.HouseholdRecipes(householdID(c))
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: err.Error(),
@@ -218,52 +283,132 @@ func (uc *UserController) GenerateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
+	filter, err := parseTagFilter(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	recipes = filter.Apply(recipes)
+	if len(recipes) == 0 {
+		httpErr := app.HTTPError{
+			Message: "no recipes found to generate from that match the tags",
+			Code:    http.StatusNotFound,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	profile, err := uc.householdDietaryProfile(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch dietary profile: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	recipes, excluded, err := profile.Filter(recipes)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+			Context: excluded,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
 
-	days := app.GenerateDays(currentYear, weekNumber)
-	for _, day := range days {
-		day.ID = uuid.New()
-		day.Dinner = app.PickRecipeForDay(day, append(prevDays, days...), recipes)
-	}
-	weeks := []*app.Week{
-		{
-			NewWeek: app.NewWeek{
-				Number: weekNumber,
-				Days:   days,
-				Year:   currentYear,
-			},
-			Entity: app.Entity{
-				ID: uuid.New(),
-			},
-		},
+	constraints, err := parseConstraints(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	seed, err := parseSeed(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if settings.WeeklyBudget > 0 {
+		constraints = append(constraints, app.Soft(app.WithinBudget(settings.WeeklyBudget, settings.HouseholdPortions), 1))
+	}
+	goals, err := parseNutritionGoals(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if len(goals) > 0 {
+		foods, err := uc.nutrientService.Foods()
+		if err != nil {
+			httpErr := app.HTTPError{
+				Message: "failed to fetch nutrient table: " + err.Error(),
+				Code:    http.StatusInternalServerError,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		table := app.NewNutrientTable(foods)
+		for _, recipe := range recipes {
+			recipe.Nutrition = recipe.PortionNutrition(table)
+		}
+		for _, goal := range goals {
+			constraints = append(constraints, app.Soft(app.MeetsGoal(goal), 1))
+		}
+	}
+	planner := app.NewPlanner(app.NewRand(seed), recipes, prevDays, constraints...)
+	planner.Decay = settings.RecencyDecay
+	planner.HouseholdPortions = settings.HouseholdPortions
+	planner.Slots = settings.MealSlots
+	planner.Goals = goals
+	planner.Pantry, err = uc.pantryService.PantryItems(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch pantry: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	weeks := app.GenerateHorizon(currentYear, weekNumber, weekCount)
+	if err = planner.PlanWeeks(weeks); err != nil {
+		httpErr := planError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	// The whole horizon is kept as a single draft so that it can be
+	// accepted or discarded as a unit.
+	days := []*app.Day{}
+	for _, w := range weeks {
+		w.Seed = seed
+		days = append(days, w.Days...)
 	}
 	newWeek := &app.NewWeek{}
-	newWeek.Days = weeks[0].Days
-	newWeek.Number = -1
+	newWeek.Days = days
+	newWeek.Number = weeks[0].Number
 	newWeek.Year = weeks[0].Year
+	newWeek.Seed = seed
+	newWeek.Status = app.WeekDraft
+	newWeek.Name = c.QueryParam("draft")
 
-	week := &app.Week{
-		NewWeek: *newWeek,
-		Entity: app.Entity{
-			ID: uuid.New(),
-		},
-	}
-
-	var lastGeneratedWeek *app.Week
-	lastGeneratedWeek, err = uc.weekService.LastGeneratedWeek(user.ID.String())
-	if err != nil || lastGeneratedWeek == nil {
-		if err.Error() == "week not found" || lastGeneratedWeek == nil {
-			lastGeneratedWeek, err = uc.weekService.CreateWeek(newWeek, user.ID.String())
-			if err != nil {
-				httpErr := app.HTTPError{
-					Message: err.Error(),
-					Code:    http.StatusInternalServerError,
-				}
-				return c.JSON(httpErr.Code, httpErr)
-			}
+	// Generating again replaces the draft with the same name.
+	week, err := uc.weekService.Draft(newWeek.Name, householdID(c))
+	if err != nil && !strings.Contains(err.Error(), "no rows in result set") {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch draft: " + err.Error(),
+			Code:    http.StatusInternalServerError,
 		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err != nil {
+		week, err = uc.weekService.CreateWeek(newWeek, householdID(c))
+	} else {
+		week.NewWeek = *newWeek
+		_, err = uc.weekService.UpdateWeek(week, householdID(c))
 	}
-	week.ID = lastGeneratedWeek.ID
-	_, err = uc.weekService.UpdateWeek(week, user.ID.String())
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: err.Error(),
@@ -271,8 +416,14 @@ func (uc *UserController) GenerateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	if len(weeks) > 0 {
-		weeks[0].ID = week.ID
+	for _, w := range weeks {
+		w.ID = week.ID
+		w.Status = app.WeekDraft
+		w.Name = week.Name
+		w.Cost = app.NewWeekCost(w.Days, settings.HouseholdPortions, settings.WeeklyBudget)
+		if len(goals) > 0 {
+			w.Goals = app.NewGoalResults(w.Days, goals)
+		}
 	}
 	return c.JSON(http.StatusOK, weeks)
 }
@@ -322,8 +473,15 @@ func (uc *UserController) SaveWeek(c echo.Context) error {
 		return c.JSON(httpErr.Code, httpErr)
 	}
 
-	dbWeeks, err := uc.weekService.CreateWeeks(weeks, user.ID.String())
+	dbWeeks, err := uc.weekService.CreateWeeks(weeks, householdID(c))
 	if err != nil {
+		if errors.Is(err, app.ErrUnknownRecipe) {
+			httpErr := app.HTTPError{
+				Message: err.Error(),
+				Code:    http.StatusUnprocessableEntity,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
 		httpErr := app.HTTPError{
 			Message: err.Error(),
 			Code:    http.StatusInternalServerError,
@@ -336,7 +494,7 @@ func (uc *UserController) SaveWeek(c echo.Context) error {
 }
 
 func (uc *UserController) DeleteWeek(c echo.Context) error {
-	user, err := getUser(uc, c)
+	_, err := getUser(uc, c)
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to fetch user") {
 			httpErr := app.HTTPError{
@@ -352,7 +510,7 @@ func (uc *UserController) DeleteWeek(c echo.Context) error {
 		return c.JSON(httpErr.Code, httpErr)
 	}
 	weekID := c.Param("weekID")
-	err = uc.weekService.DeleteWeek(weekID, user.ID.String())
+	err = uc.weekService.DeleteWeek(weekID, householdID(c))
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to delete week") {
 			httpErr := app.HTTPError{
@@ -367,7 +525,7 @@ func (uc *UserController) DeleteWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	nextWeekNumber, err := uc.weekService.NextWeekNumber(user.ID.String())
+	_, nextWeekNumber, err := uc.weekService.NextWeek(householdID(c), time.Now())
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: err.Error(),
@@ -375,16 +533,13 @@ func (uc *UserController) DeleteWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	if nextWeekNumber == 0 {
-		_, nextWeekNumber = time.Now().ISOWeek()
-	}
 	c.Response().Header().Set("X-Next-Week-Number", fmt.Sprintf("%d", nextWeekNumber))
 
 	return c.NoContent(http.StatusNoContent)
 }
 
 func (uc *UserController) NextWeekNumber(c echo.Context) error {
-	user, err := getUser(uc, c)
+	_, err := getUser(uc, c)
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to fetch user") {
 			httpErr := app.HTTPError{
@@ -400,7 +555,7 @@ func (uc *UserController) NextWeekNumber(c echo.Context) error {
 		return c.JSON(httpErr.Code, httpErr)
 	}
 
-	nextWeekNumber, err := uc.weekService.NextWeekNumber(user.ID.String())
+	_, nextWeekNumber, err := uc.weekService.NextWeek(householdID(c), time.Now())
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: err.Error(),
@@ -432,10 +587,10 @@ func (uc *UserController) GenerateRecipeAlternative(c echo.Context) error {
 	}
 	var week *app.Week
 	isLastGenerated := false
-	week, err = uc.weekService.Week(weekID, user.ID.String())
+	week, err = uc.weekService.Week(weekID, householdID(c))
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
-			week, err = uc.weekService.LastGeneratedWeek(user.ID.String())
+			week, err = uc.weekService.LastGeneratedWeek(householdID(c))
 			if err != nil {
 				httpErr := app.HTTPError{
 					Message: err.Error(),
@@ -444,9 +599,6 @@ func (uc *UserController) GenerateRecipeAlternative(c echo.Context) error {
 				return c.JSON(httpErr.Code, httpErr)
 			}
 			isLastGenerated = true
-			for _, d := range week.Days {
-				fmt.Printf("%s\n", d.Dinner.Name)
-			}
 		} else {
 			httpErr := app.HTTPError{
 				Message: "failed to fetch week: " + err.Error(),
@@ -456,7 +608,7 @@ func (uc *UserController) GenerateRecipeAlternative(c echo.Context) error {
 		}
 	}
 
-	allRecipes, err := uc.recipeService.Recipes()
+	allRecipes, err := uc.recipeService.HouseholdRecipes(householdID(c))
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "failed to fetch recipes: " + err.Error(),
@@ -464,21 +616,146 @@ func (uc *UserController) GenerateRecipeAlternative(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
+	filter, err := parseTagFilter(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	allRecipes = filter.Apply(allRecipes)
+	if len(allRecipes) == 0 {
+		httpErr := app.HTTPError{
+			Message: "no recipes found to generate from that match the tags",
+			Code:    http.StatusNotFound,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	profile, err := uc.householdDietaryProfile(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch dietary profile: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	allRecipes, excluded, err := profile.Filter(allRecipes)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+			Context: excluded,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
 
-	newSuggestion := app.PickRecipeForDay(day, week.Days, allRecipes)
+	constraints, err := parseConstraints(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	slot := app.MealDinner
+	if slotStr := c.QueryParam("slot"); slotStr != "" {
+		slot = app.MealSlot(slotStr)
+		if !slot.Valid() {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("slot need to be one of %v", app.MealSlots),
+				Code:    http.StatusBadRequest,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+	}
+	// Leave out the current recipe so that the suggestion is a new one.
+	current := day.Recipe(slot)
+	pool := []*app.Recipe{}
+	for _, recipe := range allRecipes {
+		if current == nil || recipe.Name != current.Name {
+			pool = append(pool, recipe)
+		}
+	}
+	if len(pool) == 0 {
+		pool = allRecipes
+	}
+	// The suggested meal and the leftovers of it are planned again, the
+	// rest of the week is kept.
+	suggestedDay := withoutMeal(day, slot)
+	days := []*app.Day{}
+	found := false
+	for _, d := range week.Days {
+		meal := d.Meals[slot]
+		switch {
+		case d.ID == day.ID:
+			suggestedDay = withoutMeal(d, slot)
+			days = append(days, suggestedDay)
+			found = true
+		case meal != nil && meal.IsLeftover() && *meal.LeftoverOf == day.ID:
+			days = append(days, withoutMeal(d, slot))
+		default:
+			days = append(days, d)
+		}
+	}
+	if !found {
+		days = append(days, suggestedDay)
+	}
+	seed, err := parseSeed(c)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	settings, err := uc.userService.PlannerSettings(user.ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch settings: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	weekStart := suggestedDay.Date
+	for _, d := range days {
+		if d.Date.Before(weekStart) {
+			weekStart = d.Date
+		}
+	}
+	history, err := uc.history(householdID(c), weekStart, settings.HorizonDays)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch history: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	planner := app.NewPlanner(app.NewRand(seed), pool, history, constraints...)
+	planner.Decay = settings.RecencyDecay
+	planner.HouseholdPortions = settings.HouseholdPortions
+	planner.Slots = settings.MealSlots
+	planner.Pantry, err = uc.pantryService.PantryItems(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch pantry: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err = planner.Fill(days); err != nil {
+		httpErr := planError(err)
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	newSuggestion := suggestedDay.Recipe(slot)
 
 	if isLastGenerated {
-		for _, d := range week.Days {
-			if d.ID == day.ID {
-				d.Dinner = newSuggestion
-			}
+		if found {
+			week.Days = days
 		}
-		_, err = uc.weekService.UpdateWeek(week, user.ID.String())
+		_, err = uc.weekService.UpdateWeek(week, householdID(c))
 		if err != nil {
-			httpErr := app.HTTPError{
-				Message: "failed to update week: " + err.Error(),
-				Code:    http.StatusInternalServerError,
-			}
+			httpErr := updateWeekError(err, "failed to update week")
 			return c.JSON(httpErr.Code, httpErr)
 		}
 	}
@@ -488,7 +765,7 @@ func (uc *UserController) GenerateRecipeAlternative(c echo.Context) error {
 }
 
 func (uc *UserController) UpdateWeek(c echo.Context) error {
-	user, err := getUser(uc, c)
+	_, err := getUser(uc, c)
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to fetch user") {
 			httpErr := app.HTTPError{
@@ -503,9 +780,9 @@ func (uc *UserController) UpdateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	_, err = uc.weekService.Week(c.Param("weekID"), user.ID.String())
+	_, err = uc.weekService.Week(c.Param("weekID"), householdID(c))
 	if err != nil {
-		if strings.Contains(err.Error(), "failed to fetch week") {
+		if strings.Contains(err.Error(), "no rows in result set") {
 			httpErr := app.HTTPError{
 				Message: fmt.Sprintf("week with id %s not found", c.Param("weekID")),
 				Code:    http.StatusNotFound,
@@ -526,7 +803,7 @@ func (uc *UserController) UpdateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	if week.Number == 0 || week.Number > 52 {
+	if week.Number == 0 || !isoweek.Validate(week.Year, week.Number) {
 		httpErr := app.HTTPError{
 			Message: "number need to be set between 1 and 52",
 			Code:    http.StatusUnprocessableEntity,
@@ -547,12 +824,9 @@ func (uc *UserController) UpdateWeek(c echo.Context) error {
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	week, err = uc.weekService.UpdateWeek(week, user.ID.String())
+	week, err = uc.weekService.UpdateWeek(week, householdID(c))
 	if err != nil {
-		httpErr := app.HTTPError{
-			Message: "failed to update week: " + err.Error(),
-			Code:    http.StatusInternalServerError,
-		}
+		httpErr := updateWeekError(err, "failed to update week")
 		return c.JSON(httpErr.Code, httpErr)
 	}
 
@@ -560,7 +834,7 @@ func (uc *UserController) UpdateWeek(c echo.Context) error {
 }
 
 func (uc *UserController) UpdateWeeks(c echo.Context) error {
-	user, err := getUser(uc, c)
+	_, err := getUser(uc, c)
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to fetch user") {
 			httpErr := app.HTTPError{
@@ -601,20 +875,19 @@ func (uc *UserController) UpdateWeeks(c echo.Context) error {
 		return c.JSON(httpErr.Code, httpErr)
 	}
 
-	weeks, err = uc.weekService.UpdateWeeks(weeks, user.ID.String())
+	weeks, err = uc.weekService.UpdateWeeks(weeks, householdID(c))
 	if err != nil {
-		httpErr := app.HTTPError{
-			Message: "failed to update weeks: " + err.Error(),
-			Code:    http.StatusInternalServerError,
-		}
+		httpErr := updateWeekError(err, "failed to update weeks")
 		return c.JSON(httpErr.Code, httpErr)
 	}
 
 	return c.JSON(http.StatusOK, weeks)
 }
 
+// ShuffleWeekRecipes shuffles the meals of the days of a draft in the body
+// between the days. The draft keeps its iso week.
 func (uc *UserController) ShuffleWeekRecipes(c echo.Context) error {
-	user, err := getUser(uc, c)
+	_, err := getUser(uc, c)
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to fetch user") {
 			httpErr := app.HTTPError{
@@ -648,47 +921,822 @@ func (uc *UserController) ShuffleWeekRecipes(c echo.Context) error {
 		return c.JSON(httpErr.Code, httpErr)
 	}
 
-	recipes := []*app.Recipe{}
-	for _, day := range week.Days {
-		recipes = append(recipes, day.Dinner)
-	}
-	shuffledRecipes := app.Shuffle(recipes)
-	for i, day := range week.Days {
-		day.Dinner = shuffledRecipes[i]
-	}
-	weekNum := week.Number
-	week.Number = -1
-	week, err = uc.weekService.UpdateWeek(week, user.ID.String())
+	seed, err := parseSeed(c)
 	if err != nil {
 		httpErr := app.HTTPError{
-			Message: "failed to update week: " + err.Error(),
-			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
 		}
 		return c.JSON(httpErr.Code, httpErr)
 	}
-	week.Number = weekNum
-
-	return c.JSON(http.StatusOK, week)
-}
 
-func getUser(uc *UserController, c echo.Context) (*app.User, error) {
-	fmt.Printf("%+v\n", c)
-	id := c.Param("id")
-	user, err := uc.userService.User(id)
+	stored, err := uc.weekService.Week(week.ID.String(), householdID(c))
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user, got: %s", err.Error())
-	}
-	return user, nil
-}
-
-func validateNewWeeks(weeks []*app.NewWeek) []app.ValidationError {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("week with id %s not found", week.ID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch week: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	// Accepted and archived weeks are changed by accepting a new draft.
+	if stored.Status != app.WeekDraft {
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("only drafts can be shuffled, week %s is %s", week.ID, stored.Status),
+			Code:    http.StatusConflict,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+
+	week.Seed = seed
+	rnd := app.NewRand(seed)
+	for _, slot := range app.MealSlots {
+		shuffleMeals(rnd, week.Days, slot)
+	}
+	toSave := *week
+	toSave.Number, toSave.Year = stored.Number, stored.Year
+	// A draft can span several weeks, only the shuffled days are replaced.
+	if len(stored.Days) > len(week.Days) {
+		shuffledDays := map[uuid.UUID]*app.Day{}
+		for _, day := range week.Days {
+			shuffledDays[day.ID] = day
+		}
+		for i, day := range stored.Days {
+			if shuffled, ok := shuffledDays[day.ID]; ok {
+				stored.Days[i] = shuffled
+			}
+		}
+		toSave.Days = stored.Days
+	}
+	_, err = uc.weekService.UpdateWeek(&toSave, householdID(c))
+	if err != nil {
+		httpErr := updateWeekError(err, "failed to update week")
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	week.Number, week.Year, week.Status = stored.Number, stored.Year, stored.Status
+	return c.JSON(http.StatusOK, week)
+}
+
+// requestedDraft returns the draft named by the draft query param, or the
+// last generated draft without one.
+func (uc *UserController) requestedDraft(c echo.Context) (*app.Week, error) {
+	if c.QueryParams().Has("draft") {
+		return uc.weekService.Draft(c.QueryParam("draft"), householdID(c))
+	}
+	return uc.weekService.LastGeneratedWeek(householdID(c))
+}
+
+// AcceptDraft accepts the draft named by the draft query param, or the last
+// generated draft.
+func (uc *UserController) AcceptDraft(c echo.Context) error {
+	_, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	draft, err := uc.requestedDraft(c)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: "no draft to accept",
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch draft: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return uc.acceptDraft(c, draft)
+}
+
+// AcceptWeek accepts the draft weekID.
+func (uc *UserController) AcceptWeek(c echo.Context) error {
+	weekID := c.Param("weekID")
+	draft, err := uc.weekService.Week(weekID, householdID(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("week with id %s not found", weekID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch week: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if !draft.IsDraft() {
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("week with id %s is %s, only drafts can be accepted", weekID, draft.Status),
+			Code:    http.StatusConflict,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return uc.acceptDraft(c, draft)
+}
+
+// acceptDraft saves every iso week of the draft as accepted and removes
+// the draft in one go. Weeks already accepted for the same iso weeks are
+// archived.
+func (uc *UserController) acceptDraft(c echo.Context, draft *app.Week) error {
+	newWeeks := app.SplitIntoWeeks(draft.Days)
+	for _, w := range newWeeks {
+		w.Seed = draft.Seed
+	}
+	valdationErrors := validateNewWeeks(newWeeks)
+	if len(valdationErrors) > 0 {
+		httpErr := app.HTTPError{
+			Message: "weeks validation failed",
+			Code:    http.StatusUnprocessableEntity,
+			Context: valdationErrors,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+
+	weeks, err := uc.weekService.AcceptDraft(draft.ID.String(), newWeeks, householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to accept draft: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+
+	c.Response().Header().Set("Location", fmt.Sprintf("/users/%s/weeks", c.Param("id")))
+	return c.JSON(http.StatusCreated, weeks)
+}
+
+// GetDrafts lists the drafts of the household, the most recently generated
+// first.
+func (uc *UserController) GetDrafts(c echo.Context) error {
+	drafts, err := uc.weekService.Drafts(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch drafts: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, drafts)
+}
+
+// DiscardDraft removes the draft named by the draft query param, or the last
+// generated draft, with all its weeks.
+func (uc *UserController) DiscardDraft(c echo.Context) error {
+	_, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	draft, err := uc.requestedDraft(c)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return c.NoContent(http.StatusNoContent)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch draft: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	err = uc.weekService.DeleteWeek(draft.ID.String(), householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to delete draft: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetPlannerSettings returns the settings used when generating weeks.
+func (uc *UserController) GetPlannerSettings(c echo.Context) error {
+	user, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	settings, err := uc.userService.PlannerSettings(user.ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch settings: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// UpdatePlannerSettings replaces the settings used when generating weeks.
+func (uc *UserController) UpdatePlannerSettings(c echo.Context) error {
+	user, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	settings, err := uc.userService.PlannerSettings(user.ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch settings: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err = c.Bind(settings); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to bind settings: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if validationErrors := settings.Validate(); len(validationErrors) > 0 {
+		httpErr := app.HTTPError{
+			Message: "settings validation failed",
+			Code:    http.StatusUnprocessableEntity,
+			Context: app.ValidationError{
+				Context: "settings",
+				Errors:  validationErrors,
+			},
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err = uc.userService.SavePlannerSettings(user.ID.String(), settings); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to save settings: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// GetDietaryProfile returns the dietary restrictions that recipes are
+// picked by.
+func (uc *UserController) GetDietaryProfile(c echo.Context) error {
+	user, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	profile, err := uc.userService.DietaryProfile(user.ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch dietary profile: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// UpdateDietaryProfile replaces the dietary restrictions that recipes are
+// picked by.
+func (uc *UserController) UpdateDietaryProfile(c echo.Context) error {
+	user, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	profile := &app.DietaryProfile{}
+	if err = c.Bind(profile); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to bind dietary profile: " + err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if validationErrors := profile.Validate(); len(validationErrors) > 0 {
+		httpErr := app.HTTPError{
+			Message: "dietary profile validation failed",
+			Code:    http.StatusUnprocessableEntity,
+			Context: app.ValidationError{
+				Context: "dietary profile",
+				Errors:  validationErrors,
+			},
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err = uc.userService.SaveDietaryProfile(user.ID.String(), profile); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to save dietary profile: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// GetShoppingList returns what to buy for the meals of a week, scaled to
+// the portions of the household.
+func (uc *UserController) GetShoppingList(c echo.Context) error {
+	user, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	weekID := c.Param("weekID")
+	week, err := uc.weekService.Week(weekID, householdID(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("week with id %s not found", weekID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch week: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	settings, err := uc.userService.PlannerSettings(user.ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch settings: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	// The week holds the recipes as they were when it was planned, shop for
+	// them as they are now.
+	if err = uc.withCurrentRecipes(householdID(c), week.Days); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch recipes: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	pantry, err := uc.pantryService.PantryItems(householdID(c))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch pantry: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	list := app.NewShoppingList(week.Days, settings.HouseholdPortions)
+	list.SubtractPantry(pantry, time.Now())
+	return c.JSON(http.StatusOK, list)
+}
+
+// GetWeekNutrition returns what one person eats during a week, per day and
+// on average.
+func (uc *UserController) GetWeekNutrition(c echo.Context) error {
+	_, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	weekID := c.Param("weekID")
+	week, err := uc.weekService.Week(weekID, householdID(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("week with id %s not found", weekID),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch week: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err = uc.withCurrentRecipes(householdID(c), week.Days); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch recipes: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	foods, err := uc.nutrientService.Foods()
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch nutrient table: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, app.NewWeekNutrition(week.Days, app.NewNutrientTable(foods)))
+}
+
+// GetBudgetReport compares the planned spend of every saved week of a year
+// to the weekly budget.
+func (uc *UserController) GetBudgetReport(c echo.Context) error {
+	user, err := getUser(uc, c)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to fetch user") {
+			httpErr := app.HTTPError{
+				Message: fmt.Sprintf("user with id %s not found", c.Param("id")),
+				Code:    http.StatusNotFound,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: "failed to fetch user: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "year need to be a number",
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	settings, err := uc.userService.PlannerSettings(user.ID.String())
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch settings: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	weeks, err := uc.weekService.Weeks(householdID(c), year)
+	if err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch weeks: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	days := []*app.Day{}
+	for _, week := range weeks {
+		days = append(days, week.Days...)
+	}
+	if err = uc.withCurrentRecipes(householdID(c), days); err != nil {
+		httpErr := app.HTTPError{
+			Message: "failed to fetch recipes: " + err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.JSON(http.StatusOK, app.NewBudgetReport(year, weeks, settings.HouseholdPortions, settings.WeeklyBudget))
+}
+
+// withCurrentRecipes replaces the recipes of saved days, as they were when
+// the days were planned, with the recipes as they are now. Recipes that
+// have been deleted are kept as they were.
+func (uc *UserController) withCurrentRecipes(householdID string, days []*app.Day) error {
+	recipes, err := uc.recipeService.HouseholdRecipes(householdID)
+	if err != nil {
+		return err
+	}
+	current := map[uuid.UUID]*app.Recipe{}
+	for _, recipe := range recipes {
+		current[recipe.ID] = recipe
+	}
+	for _, day := range days {
+		for _, meal := range day.Meals {
+			if meal.Recipe == nil {
+				continue
+			}
+			if recipe, ok := current[meal.Recipe.ID]; ok {
+				meal.Recipe = recipe
+			}
+		}
+	}
+	return nil
+}
+
+// householdDietaryProfile merges the dietary profiles of every member of
+// the household, so that planned meals suit all of them.
+func (uc *UserController) householdDietaryProfile(householdID string) (*app.DietaryProfile, error) {
+	household, err := uc.householdService.Household(householdID)
+	if err != nil {
+		return nil, err
+	}
+	profile := &app.DietaryProfile{}
+	for _, member := range household.Members {
+		memberProfile, err := uc.userService.DietaryProfile(member.UserID.String())
+		if err != nil {
+			return nil, err
+		}
+		profile = profile.Merge(memberProfile)
+	}
+	return profile, nil
+}
+
+// history returns the saved days of the household in the days before
+// before, covering at least the no repeat period.
+func (uc *UserController) history(householdID string, before time.Time, days int) ([]*app.Day, error) {
+	if days < app.DefaultNoRepeatDays {
+		days = app.DefaultNoRepeatDays
+	}
+	from := before.AddDate(0, 0, -days)
+	fromYear, _ := from.ISOWeek()
+	beforeYear, _ := before.ISOWeek()
+	history := []*app.Day{}
+	for year := fromYear; year <= beforeYear; year++ {
+		weeks, err := uc.weekService.Weeks(householdID, year)
+		if err != nil {
+			return nil, err
+		}
+		for _, week := range weeks {
+			for _, day := range week.Days {
+				if day.Date.Before(before) && !day.Date.Before(from) {
+					history = append(history, day)
+				}
+			}
+		}
+	}
+	return history, nil
+}
+
+// shuffleMeals shuffles the recipes cooked in slot between days. Leftovers
+// stay in place and follow the day they are leftovers of, as long as its
+// new recipe can be eaten as leftovers.
+func shuffleMeals(rnd *rand.Rand, days []*app.Day, slot app.MealSlot) {
+	recipes := []*app.Recipe{}
+	cooked := []*app.Meal{}
+	for _, day := range days {
+		if meal, ok := day.Meals[slot]; ok && !meal.IsLeftover() {
+			recipes = append(recipes, meal.Recipe)
+			cooked = append(cooked, meal)
+		}
+	}
+	shuffledRecipes := app.Shuffle(rnd, recipes)
+	for i, meal := range cooked {
+		meal.Recipe = shuffledRecipes[i]
+	}
+
+	cookedOn := map[uuid.UUID]*app.Recipe{}
+	for _, day := range days {
+		if meal, ok := day.Meals[slot]; ok && !meal.IsLeftover() {
+			cookedOn[day.ID] = meal.Recipe
+		}
+	}
+	for _, day := range days {
+		meal, ok := day.Meals[slot]
+		if !ok || !meal.IsLeftover() {
+			continue
+		}
+		if recipe := cookedOn[*meal.LeftoverOf]; recipe != nil && recipe.LeftOverCompliance {
+			meal.Recipe = recipe
+		} else {
+			meal.LeftoverOf = nil
+		}
+	}
+}
+
+// withoutMeal returns a copy of day where the meal in slot is yet to be
+// planned.
+func withoutMeal(day *app.Day, slot app.MealSlot) *app.Day {
+	meals := map[app.MealSlot]*app.Meal{}
+	for s, meal := range day.Meals {
+		meals[s] = meal
+	}
+	meals[slot] = &app.Meal{}
+	return &app.Day{
+		Date:   day.Date,
+		Meals:  meals,
+		Entity: day.Entity,
+	}
+}
+
+func getUser(uc *UserController, c echo.Context) (*app.User, error) {
+	fmt.Printf("%+v\n", c)
+	id := c.Param("id")
+	user, err := uc.userService.User(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user, got: %s", err.Error())
+	}
+	return user, nil
+}
+
+// parseConstraints reads the planner constraints of a generate request. The
+// no repeat rule is always included, at_least and at_most take values like
+// "fish:1" or "pasta:2:soft" matched against the recipe names.
+func parseConstraints(c echo.Context) ([]app.Constraint, error) {
+	constraints := []app.Constraint{
+		app.NoRepeatWithin(app.DefaultNoRepeatDays),
+	}
+	params := c.QueryParams()
+	for _, kind := range []string{"at_least", "at_most"} {
+		for _, value := range params[kind] {
+			parts := strings.Split(value, ":")
+			if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+				return nil, fmt.Errorf("%s must be formatted as term:count[:soft], got %q", kind, value)
+			}
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("%s count must be a non-negative number, got %q", kind, parts[1])
+			}
+			var constraint app.Constraint
+			if kind == "at_least" {
+				constraint = app.AtLeast(count, parts[0], app.NameContains(parts[0]))
+			} else {
+				constraint = app.AtMost(count, parts[0], app.NameContains(parts[0]))
+			}
+			if len(parts) == 3 {
+				if parts[2] != "soft" {
+					return nil, fmt.Errorf("%s only accepts soft as modifier, got %q", kind, parts[2])
+				}
+				constraint = app.Soft(constraint, 1)
+			}
+			constraints = append(constraints, constraint)
+		}
+	}
+	return constraints, nil
+}
+
+// parseNutritionGoals reads the goal query parameters, e.g. goal=protein>=30
+// for at least 30 g protein per dinner on average.
+func parseNutritionGoals(c echo.Context) ([]app.NutritionGoal, error) {
+	goals := []app.NutritionGoal{}
+	for _, value := range c.QueryParams()["goal"] {
+		goal, err := app.ParseNutritionGoal(value)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	return goals, nil
+}
+
+// parseTagFilter reads the tag query parameters a recipe pool is filtered
+// with, e.g. tag=diet:vegetarian to only keep vegetarian recipes and
+// exclude=oven to leave out recipes tagged oven.
+func parseTagFilter(c echo.Context) (app.TagFilter, error) {
+	filter := app.TagFilter{}
+	params := c.QueryParams()
+	for _, value := range params["tag"] {
+		tag, err := app.ParseTag(value)
+		if err != nil {
+			return app.TagFilter{}, fmt.Errorf("tag: %w", err)
+		}
+		filter.Include = append(filter.Include, tag)
+	}
+	for _, value := range params["exclude"] {
+		tag, err := app.ParseTag(value)
+		if err != nil {
+			return app.TagFilter{}, fmt.Errorf("exclude: %w", err)
+		}
+		filter.Exclude = append(filter.Exclude, tag)
+	}
+	return filter, nil
+}
+
+// parseSeed returns the seed query parameter, or a new seed when the
+// request has none, so that the plan can be regenerated later.
+func parseSeed(c echo.Context) (int64, error) {
+	seedStr := c.QueryParam("seed")
+	if seedStr == "" {
+		return app.NewSeed(), nil
+	}
+	seed, err := strconv.ParseInt(seedStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("seed must be a number, got %q", seedStr)
+	}
+	return seed, nil
+}
+
+// updateWeekError maps errors from updating weeks to http errors, failure
+// says what failed.
+func updateWeekError(err error, failure string) app.HTTPError {
+	switch {
+	case errors.Is(err, app.ErrUnknownRecipe):
+		return app.HTTPError{Message: err.Error(), Code: http.StatusUnprocessableEntity}
+	case errors.Is(err, app.ErrWeekArchived):
+		return app.HTTPError{Message: err.Error(), Code: http.StatusConflict}
+	case strings.Contains(err.Error(), "no rows in result set"):
+		return app.HTTPError{Message: err.Error(), Code: http.StatusNotFound}
+	}
+	return app.HTTPError{Message: failure + ": " + err.Error(), Code: http.StatusInternalServerError}
+}
+
+// planError maps planner errors to http errors.
+func planError(err error) app.HTTPError {
+	var infeasible *app.InfeasibleError
+	if errors.As(err, &infeasible) {
+		return app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+			Context: infeasible,
+		}
+	}
+	if errors.Is(err, app.ErrEmptyRecipePool) {
+		return app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusNotFound,
+		}
+	}
+	if errors.Is(err, app.ErrSearchExhausted) {
+		return app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	return app.HTTPError{
+		Message: err.Error(),
+		Code:    http.StatusInternalServerError,
+	}
+}
+
+func validateNewWeeks(weeks []*app.NewWeek) []app.ValidationError {
 	errors := []app.ValidationError{}
 	for _, week := range weeks {
 		err := app.ValidationError{
 			Context: fmt.Sprintf("week %d", week.Number),
 			Errors:  []string{},
 		}
-		if week.Number == 0 || week.Number > 52 {
+		if week.Number == 0 || !isoweek.Validate(week.Year, week.Number) {
 			err.Errors = append(err.Errors, "number need to be set between 1 and 52")
 		}
 		if week.Year == 0 {
@@ -697,6 +1745,10 @@ func validateNewWeeks(weeks []*app.NewWeek) []app.ValidationError {
 		if len(week.Days) == 0 {
 			err.Errors = append(err.Errors, "days need to be set")
 		}
+		// Weeks are archived when another week replaces them.
+		if week.Status != "" && week.Status != app.WeekDraft && week.Status != app.WeekAccepted {
+			err.Errors = append(err.Errors, fmt.Sprintf("status need to be %s or %s", app.WeekDraft, app.WeekAccepted))
+		}
 		if len(err.Errors) > 0 {
 			errors = append(errors, err)
 		}
@@ -712,7 +1764,7 @@ func validateWeeks(weeks []*app.Week) []app.ValidationError {
 			Context: fmt.Sprintf("week %d", week.Number),
 			Errors:  []string{},
 		}
-		if week.Number == 0 || week.Number > 52 {
+		if week.Number == 0 || !isoweek.Validate(week.Year, week.Number) {
 			err.Errors = append(err.Errors, "number need to be set between 1 and 52")
 		}
 		if week.Year == 0 {
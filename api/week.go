@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -18,7 +19,7 @@ func NewWeekController(weekService app.WeekService) *WeekController {
 }
 
 func (wc *WeekController) GetWeeks(c echo.Context) error {
-	id := c.Param("id")
+	id := householdID(c)
 	yearStr := c.Param("year")
 	year, err := strconv.Atoi(yearStr)
 	if err != nil {
@@ -28,7 +29,19 @@ func (wc *WeekController) GetWeeks(c echo.Context) error {
 		}
 		return c.JSON(http.StatusBadRequest, httpErr)
 	}
-	weeks, err := wc.weekService.Weeks(id, year)
+	var weeks []*app.Week
+	switch status := app.WeekStatus(c.QueryParam("status")); status {
+	case "", app.WeekAccepted:
+		weeks, err = wc.weekService.Weeks(id, year)
+	case app.WeekArchived:
+		weeks, err = wc.weekService.ArchivedWeeks(id, year)
+	default:
+		httpErr := app.HTTPError{
+			Message: fmt.Sprintf("status need to be %s or %s", app.WeekAccepted, app.WeekArchived),
+			Code:    http.StatusBadRequest,
+		}
+		return c.JSON(http.StatusBadRequest, httpErr)
+	}
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "Error: " + err.Error(),
@@ -40,7 +53,7 @@ func (wc *WeekController) GetWeeks(c echo.Context) error {
 }
 
 func (wc *WeekController) GetLastGeneratedWeek(c echo.Context) error {
-	id := c.Param("id")
+	id := householdID(c)
 	week, err := wc.weekService.LastGeneratedWeek(id)
 	if err != nil {
 		httpErr := app.HTTPError{
@@ -53,7 +66,7 @@ func (wc *WeekController) GetLastGeneratedWeek(c echo.Context) error {
 }
 
 func (wc *WeekController) CreateWeek(c echo.Context) error {
-	userID := c.Param("id")
+	id := householdID(c)
 	newWeek := app.NewWeek{}
 	if err := c.Bind(&newWeek); err != nil {
 		return c.String(http.StatusBadRequest, "Error: "+err.Error())
@@ -74,7 +87,7 @@ func (wc *WeekController) CreateWeek(c echo.Context) error {
 		return c.JSON(http.StatusUnprocessableEntity, httpErr)
 	}
 
-	week, err := wc.weekService.CreateWeek(&newWeek, userID)
+	week, err := wc.weekService.CreateWeek(&newWeek, id)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, "Error: "+err.Error())
 	}
@@ -82,7 +95,7 @@ func (wc *WeekController) CreateWeek(c echo.Context) error {
 }
 
 func (wc *WeekController) CreateWeeks(c echo.Context) error {
-	userID := c.Param("id")
+	id := householdID(c)
 	newWeeks := []*app.NewWeek{}
 	if err := c.Bind(&newWeeks); err != nil {
 		httpErr := app.HTTPError{
@@ -92,7 +105,7 @@ func (wc *WeekController) CreateWeeks(c echo.Context) error {
 		return c.JSON(http.StatusUnprocessableEntity, httpErr)
 	}
 
-	weeks, err := wc.weekService.CreateWeeks(newWeeks, userID)
+	weeks, err := wc.weekService.CreateWeeks(newWeeks, id)
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "Error: " + err.Error(),
@@ -104,7 +117,7 @@ func (wc *WeekController) CreateWeeks(c echo.Context) error {
 }
 
 func (wc *WeekController) DeleteWeeks(c echo.Context) error {
-	userID := c.Param("id")
+	id := householdID(c)
 	yearStr := c.Param("year")
 	year, err := strconv.Atoi(yearStr)
 	if err != nil {
@@ -114,7 +127,7 @@ func (wc *WeekController) DeleteWeeks(c echo.Context) error {
 		}
 		return c.JSON(http.StatusBadRequest, httpErr)
 	}
-	err = wc.weekService.DeleteWeeks(userID, year)
+	err = wc.weekService.DeleteWeeks(id, year)
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: "Error: " + err.Error(),
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APITokenPrefix starts every personal API token, telling them apart from
+// the tokens of sessions.
+const APITokenPrefix = "msp_"
+
+// ErrMissingScope is returned for API tokens used for more than their
+// scopes allow.
+var ErrMissingScope = errors.New("API token not allowed")
+
+// Scope is what an API token may be used for, a resource and read or
+// write, where write includes read.
+type Scope string
+
+const (
+	ScopeWeeksRead        Scope = "weeks:read"
+	ScopeWeeksWrite       Scope = "weeks:write"
+	ScopeRecipesRead      Scope = "recipes:read"
+	ScopeRecipesWrite     Scope = "recipes:write"
+	ScopePantryRead       Scope = "pantry:read"
+	ScopePantryWrite      Scope = "pantry:write"
+	ScopeShoppingListRead Scope = "shopping-list:read"
+)
+
+var Scopes = []Scope{
+	ScopeWeeksRead, ScopeWeeksWrite,
+	ScopeRecipesRead, ScopeRecipesWrite,
+	ScopePantryRead, ScopePantryWrite,
+	ScopeShoppingListRead,
+}
+
+func (s Scope) Valid() bool {
+	for _, scope := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIToken is what a user asks for when creating an API token.
+type NewAPIToken struct {
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+// APIToken is a long-lived token for scripts and integrations to use the
+// API as a user, limited to its scopes. Only a hash of it is stored, Token
+// is only set when it is created.
+type APIToken struct {
+	NewAPIToken
+	UserID     uuid.UUID  `json:"user_id"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Entity
+}
+
+// Allows reports whether the token may be used for what scope is needed
+// for.
+func (t *APIToken) Allows(scope Scope) bool {
+	resource, access, _ := strings.Cut(string(scope), ":")
+	for _, granted := range t.Scopes {
+		if granted == scope || (access == "read" && granted == Scope(resource+":write")) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateAPIToken returns a new random API token.
+func GenerateAPIToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPITokenAllows(t *testing.T) {
+	token := &APIToken{NewAPIToken: NewAPIToken{Scopes: []Scope{ScopeWeeksRead, ScopeRecipesWrite}}}
+	tests := []struct {
+		scope  Scope
+		allows bool
+	}{
+		{ScopeWeeksRead, true},
+		{ScopeWeeksWrite, false},
+		{ScopeRecipesRead, true},
+		{ScopeRecipesWrite, true},
+		{ScopeShoppingListRead, false},
+		{ScopePantryRead, false},
+	}
+	for _, test := range tests {
+		if allows := token.Allows(test.scope); allows != test.allows {
+			t.Errorf("Expected %s to be allowed %t, got %t", test.scope, test.allows, allows)
+		}
+	}
+}
+
+func TestGenerateAPIToken(t *testing.T) {
+	a, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || !strings.HasPrefix(a, APITokenPrefix) || len(a) < 40 {
+		t.Errorf("Expected distinct, prefixed tokens, got %q and %q", a, b)
+	}
+}
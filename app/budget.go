@@ -0,0 +1,168 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// cookedMeal is a meal cooked on day, with its recipe scaled to the
+// portions eaten of it.
+type cookedMeal struct {
+	day    *Day
+	slot   MealSlot
+	recipe Recipe
+}
+
+// cookedMeals returns the meals cooked during days with the recipes scaled
+// so that every meal feeds portions people. Leftovers are cooked with the
+// meal they are leftovers of, which is scaled up to cover them. A portions
+// of 0 keeps the portions of the recipes.
+func cookedMeals(days []*Day, portions int) []cookedMeal {
+	type cooking struct {
+		day  uuid.UUID
+		slot MealSlot
+	}
+	servings := map[cooking]int{}
+	for _, sm := range mealsOf(days) {
+		if sm.meal.Recipe == nil {
+			continue
+		}
+		if sm.meal.IsLeftover() {
+			servings[cooking{*sm.meal.LeftoverOf, sm.slot}]++
+		} else {
+			servings[cooking{sm.day.ID, sm.slot}]++
+		}
+	}
+
+	meals := []cookedMeal{}
+	for _, sm := range mealsOf(days) {
+		if !sm.cooked() {
+			continue
+		}
+		recipe := *sm.meal.Recipe
+		if portions > 0 && recipe.Portions > 0 {
+			recipe.AlterPortions(portions * servings[cooking{sm.day.ID, sm.slot}])
+		}
+		meals = append(meals, cookedMeal{day: sm.day, slot: sm.slot, recipe: recipe})
+	}
+	return meals
+}
+
+type DayCost struct {
+	Date time.Time `json:"date"`
+	Cost float64   `json:"cost"`
+}
+
+// WeekCost is the projected cost of a planned week. Leftovers cost nothing
+// on the day they are eaten, they are paid for with the meal they are
+// leftovers of.
+type WeekCost struct {
+	Cost float64   `json:"cost"`
+	Days []DayCost `json:"days"`
+	// Budget is the weekly budget the week was planned against, 0 if
+	// there is none.
+	Budget     float64 `json:"budget,omitempty"`
+	OverBudget bool    `json:"over_budget"`
+}
+
+// NewWeekCost returns the cost of cooking the meals of days for portions
+// people.
+func NewWeekCost(days []*Day, portions int, budget float64) *WeekCost {
+	cost := &WeekCost{Days: make([]DayCost, len(days)), Budget: budget}
+	index := map[*Day]int{}
+	for i, day := range days {
+		cost.Days[i].Date = day.Date
+		index[day] = i
+	}
+	for _, meal := range cookedMeals(days, portions) {
+		mealCost := meal.recipe.Cost()
+		cost.Days[index[meal.day]].Cost += mealCost
+		cost.Cost += mealCost
+	}
+	cost.OverBudget = budget > 0 && cost.Cost > budget
+	return cost
+}
+
+type budgetConstraint struct {
+	budget   float64
+	portions int
+}
+
+// WithinBudget limits what the meals of each iso week cost to budget, with
+// the recipes scaled to portions people. Every tenth of the budget spent
+// over it is a violation.
+func WithinBudget(budget float64, portions int) Constraint {
+	return &budgetConstraint{budget: budget, portions: portions}
+}
+
+func (c *budgetConstraint) Name() string {
+	return fmt.Sprintf("within budget of %g", c.budget)
+}
+
+func (c *budgetConstraint) Hard() bool      { return true }
+func (c *budgetConstraint) Weight() float64 { return 1 }
+
+func (c *budgetConstraint) Violations(days, _ []*Day) int {
+	if c.budget <= 0 {
+		return 0
+	}
+	type isoWeek struct{ year, number int }
+	costs := map[isoWeek]float64{}
+	for _, meal := range cookedMeals(days, c.portions) {
+		year, number := meal.day.Date.ISOWeek()
+		costs[isoWeek{year, number}] += meal.recipe.Cost()
+	}
+	violations := 0
+	for _, cost := range costs {
+		if cost > c.budget {
+			violations += int(math.Ceil((cost - c.budget) / c.budget * 10))
+		}
+	}
+	return violations
+}
+
+// BudgetWeek compares the planned spend of a saved week to the budget.
+type BudgetWeek struct {
+	Number int     `json:"number"`
+	Cost   float64 `json:"cost"`
+	// Remaining is what is left of the budget, negative when over it and
+	// zero without a budget.
+	Remaining  float64 `json:"remaining"`
+	OverBudget bool    `json:"over_budget"`
+}
+
+// BudgetReport compares the planned spend of the saved weeks of a year to
+// the weekly budget.
+type BudgetReport struct {
+	Year   int          `json:"year"`
+	Budget float64      `json:"budget"`
+	Weeks  []BudgetWeek `json:"weeks"`
+	// Cost and Remaining sum the weeks.
+	Cost      float64 `json:"cost"`
+	Remaining float64 `json:"remaining"`
+}
+
+// NewBudgetReport returns the planned spend of weeks, cooked for portions
+// people, against the weekly budget.
+func NewBudgetReport(year int, weeks []*Week, portions int, budget float64) *BudgetReport {
+	report := &BudgetReport{Year: year, Budget: budget, Weeks: []BudgetWeek{}}
+	for _, week := range weeks {
+		cost := NewWeekCost(week.Days, portions, budget)
+		remaining := 0.0
+		if budget > 0 {
+			remaining = budget - cost.Cost
+		}
+		report.Weeks = append(report.Weeks, BudgetWeek{
+			Number:     week.Number,
+			Cost:       cost.Cost,
+			Remaining:  remaining,
+			OverBudget: cost.OverBudget,
+		})
+		report.Cost += cost.Cost
+		report.Remaining += remaining
+	}
+	return report
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func pricedRecipe(name string, price int) *Recipe {
+	return &Recipe{NewRecipe: NewRecipe{
+		Name:              name,
+		Portions:          4,
+		ProbabilityWeight: 1,
+		Items:             []*Item{{Name: name, Amount: 1, Unit: "st", Price: price}},
+	}}
+}
+
+func TestWeekCostPaysLeftoversWithTheirMeal(t *testing.T) {
+	stew := pricedRecipe("Stew", 100)
+	days := GenerateDays(2023, 36)
+	for _, day := range days {
+		day.ID = uuid.New()
+	}
+	days[0].SetRecipe(MealDinner, stew)
+	days[1].Meals = map[MealSlot]*Meal{MealDinner: {Recipe: stew, LeftoverOf: &days[0].ID}}
+	days[2].SetRecipe(MealDinner, pricedRecipe("Soup", 40))
+
+	cost := NewWeekCost(days, 2, 120)
+
+	if cost.Days[0].Cost != 100 || cost.Days[1].Cost != 0 || cost.Days[2].Cost != 20 {
+		t.Errorf("Expected day costs 100, 0 and 20, got %v", cost.Days[:3])
+	}
+	if cost.Cost != 120 || cost.OverBudget {
+		t.Errorf("Expected 120 within budget, got %v over budget %v", cost.Cost, cost.OverBudget)
+	}
+}
+
+func TestPlanWeekPrefersStayingWithinBudget(t *testing.T) {
+	recipes := []*Recipe{}
+	for i := 0; i < 7; i++ {
+		recipes = append(recipes, pricedRecipe(fmt.Sprintf("Cheap %d", i), 10))
+		recipes = append(recipes, pricedRecipe(fmt.Sprintf("Expensive %d", i), 100))
+	}
+	planner := NewPlanner(NewRand(1), recipes, nil,
+		NoRepeatWithin(DefaultNoRepeatDays),
+		Soft(WithinBudget(250, 4), 1),
+	)
+	planner.Attempts = 200
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := NewWeekCost(week.Days, 4, 250); cost.OverBudget {
+		t.Errorf("Expected the week to stay within 250, got %v", cost.Cost)
+	}
+}
+
+func TestBudgetReport(t *testing.T) {
+	weeks := []*Week{
+		{NewWeek: NewWeek{Number: 1, Days: GenerateDays(2023, 1)}},
+		{NewWeek: NewWeek{Number: 2, Days: GenerateDays(2023, 2)}},
+	}
+	for _, week := range weeks {
+		for _, day := range week.Days {
+			day.SetRecipe(MealDinner, pricedRecipe("Dinner", 10*week.Number))
+		}
+	}
+
+	report := NewBudgetReport(2023, weeks, 0, 100)
+
+	if report.Weeks[0].Cost != 70 || report.Weeks[0].OverBudget {
+		t.Errorf("Expected week 1 to cost 70 within budget, got %+v", report.Weeks[0])
+	}
+	if report.Weeks[1].Remaining != -40 || !report.Weeks[1].OverBudget {
+		t.Errorf("Expected week 2 to be 40 over budget, got %+v", report.Weeks[1])
+	}
+	if report.Cost != 210 || report.Remaining != -10 {
+		t.Errorf("Expected 210 spent and -10 remaining, got %v and %v", report.Cost, report.Remaining)
+	}
+}
+
+func TestBudgetReportWithoutBudget(t *testing.T) {
+	week := &Week{NewWeek: NewWeek{Number: 1, Days: GenerateDays(2023, 1)}}
+	for _, day := range week.Days {
+		day.SetRecipe(MealDinner, pricedRecipe("Dinner", 10))
+	}
+
+	report := NewBudgetReport(2023, []*Week{week}, 0, 0)
+
+	if report.Weeks[0].Cost != 70 || report.Weeks[0].OverBudget || report.Weeks[0].Remaining != 0 {
+		t.Errorf("Expected a week without budget to cost 70 and not be over it, got %+v", report.Weeks[0])
+	}
+	if report.Remaining != 0 {
+		t.Errorf("Expected nothing remaining without a budget, got %v", report.Remaining)
+	}
+}
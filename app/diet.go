@@ -0,0 +1,246 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrAllRecipesExcluded is returned when the dietary restrictions leave no
+// recipe to plan.
+var ErrAllRecipesExcluded = errors.New("every recipe is excluded by the dietary restrictions")
+
+// Allergen is a substance some people can not eat.
+type Allergen string
+
+const (
+	AllergenGluten    Allergen = "gluten"
+	AllergenLactose   Allergen = "lactose"
+	AllergenEgg       Allergen = "egg"
+	AllergenFish      Allergen = "fish"
+	AllergenShellfish Allergen = "shellfish"
+	AllergenPeanut    Allergen = "peanut"
+	AllergenNuts      Allergen = "nuts"
+	AllergenSoy       Allergen = "soy"
+	AllergenSesame    Allergen = "sesame"
+	AllergenCelery    Allergen = "celery"
+	AllergenMustard   Allergen = "mustard"
+)
+
+// Allergens lists every known allergen.
+var Allergens = []Allergen{
+	AllergenGluten, AllergenLactose, AllergenEgg, AllergenFish, AllergenShellfish,
+	AllergenPeanut, AllergenNuts, AllergenSoy, AllergenSesame, AllergenCelery, AllergenMustard,
+}
+
+func (a Allergen) Valid() bool {
+	for _, allergen := range Allergens {
+		if a == allergen {
+			return true
+		}
+	}
+	return false
+}
+
+// allergenKeywords are parts of ingredient names, in English and Swedish,
+// that give away an allergen. A keyword ending in $ only matches the end of
+// a word, so that mjöl$ is found in vetemjöl but not in mjölk. They err on
+// the side of finding an allergen that is not there.
+var allergenKeywords = map[Allergen][]string{
+	AllergenGluten:    {"wheat", "vete", "flour", "mjöl$", "pasta", "spaghetti", "noodle", "nudlar", "bread", "bröd", "couscous", "bulgur", "barley", "rye", "råg", "tortilla", "panko", "ströbröd"},
+	AllergenLactose:   {"milk", "mjölk", "cream", "grädde", "butter", "smör", "cheese", "ost$", "yoghurt", "yogurt", "fraiche", "fraîche", "kvarg", "keso", "parmesan", "mozzarella", "halloumi", "feta"},
+	AllergenEgg:       {"egg", "ägg", "majonnäs", "mayonnaise"},
+	AllergenFish:      {"fish", "fisk", "salmon", "lax", "cod", "torsk", "tuna", "tonfisk", "sill", "herring", "anchov", "ansjovis", "sej", "kolja", "makrill"},
+	AllergenShellfish: {"shrimp", "räk", "prawn", "crab", "krabba", "lobster", "hummer", "kräft", "mussel", "musslor", "scampi"},
+	AllergenPeanut:    {"peanut", "jordnöt"},
+	AllergenNuts:      {"almond", "mandel", "cashew", "hazelnut", "hasselnöt", "walnut", "valnöt", "pecan", "pistach", "nötter", "nuts"},
+	AllergenSoy:       {"soy", "soja", "tofu", "edamame", "miso", "tempeh"},
+	AllergenSesame:    {"sesam", "tahini"},
+	AllergenCelery:    {"celery", "selleri"},
+	AllergenMustard:   {"mustard", "senap"},
+}
+
+// meatKeywords are parts of ingredient names that make a recipe not
+// vegetarian, on top of fish and shellfish. They only explain why a recipe
+// is left out, recipes without them are not vegetarian unless tagged so.
+var meatKeywords = []string{
+	"meat", "kött", "färs", "mince", "beef", "pork", "fläsk", "bacon", "pancetta", "chicken", "kyckling",
+	"skinka", "ham$", "prosciutto", "sausage", "korv", "chorizo", "salami", "lamb", "lamm", "turkey", "kalkon",
+	"duck", "anka", "vilt", "älg", "hjort", "steak", "biff", "entrecôte", "entrecote", "oxfilé", "veal", "kalv",
+	"lever", "liver", "gelatin", "höns", "oxbuljong",
+}
+
+func containsAny(name string, keywords []string) bool {
+	name = strings.ToLower(name)
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, keyword := range keywords {
+		if suffix, ok := strings.CutSuffix(keyword, "$"); ok {
+			for _, word := range words {
+				if strings.HasSuffix(word, suffix) {
+					return true
+				}
+			}
+			continue
+		}
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// ItemAllergens returns the allergens of an ingredient, those set on it and
+// those given away by its name.
+func ItemAllergens(item *Item) []Allergen {
+	allergens := []Allergen{}
+	for _, allergen := range Allergens {
+		found := containsAny(item.Name, allergenKeywords[allergen])
+		for _, a := range item.Allergens {
+			if a == allergen {
+				found = true
+			}
+		}
+		if found {
+			allergens = append(allergens, allergen)
+		}
+	}
+	return allergens
+}
+
+// Allergens returns the allergens of every ingredient of the recipe.
+func (r *Recipe) Allergens() []Allergen {
+	found := map[Allergen]bool{}
+	for _, item := range r.Items {
+		for _, allergen := range ItemAllergens(item) {
+			found[allergen] = true
+		}
+	}
+	allergens := []Allergen{}
+	for _, allergen := range Allergens {
+		if found[allergen] {
+			allergens = append(allergens, allergen)
+		}
+	}
+	return allergens
+}
+
+// DietaryProfile holds what a member of the household can not or will not
+// eat.
+type DietaryProfile struct {
+	Vegetarian  bool `json:"vegetarian"`
+	GlutenFree  bool `json:"gluten_free"`
+	LactoseFree bool `json:"lactose_free"`
+	// Allergens are left out on top of gluten and lactose.
+	Allergens []Allergen `json:"allergens,omitempty"`
+	// ExcludedIngredients leave out recipes with an ingredient whose name
+	// contains one of them.
+	ExcludedIngredients []string `json:"excluded_ingredients,omitempty"`
+	ExcludedTags        []Tag    `json:"excluded_tags,omitempty"`
+}
+
+// Validate returns a list of problems with the profile, empty if it can be
+// saved.
+func (p *DietaryProfile) Validate() []string {
+	errors := []string{}
+	for _, allergen := range p.Allergens {
+		if !allergen.Valid() {
+			errors = append(errors, fmt.Sprintf("allergens can only contain %v, got %q", Allergens, allergen))
+		}
+	}
+	for _, ingredient := range p.ExcludedIngredients {
+		if strings.TrimSpace(ingredient) == "" {
+			errors = append(errors, "excluded_ingredients can not contain empty names")
+		}
+	}
+	return errors
+}
+
+// Merge returns a profile that excludes everything either profile does,
+// for planning meals that everyone in a household can eat.
+func (p *DietaryProfile) Merge(other *DietaryProfile) *DietaryProfile {
+	return &DietaryProfile{
+		Vegetarian:          p.Vegetarian || other.Vegetarian,
+		GlutenFree:          p.GlutenFree || other.GlutenFree,
+		LactoseFree:         p.LactoseFree || other.LactoseFree,
+		Allergens:           append(append([]Allergen{}, p.Allergens...), other.Allergens...),
+		ExcludedIngredients: append(append([]string{}, p.ExcludedIngredients...), other.ExcludedIngredients...),
+		ExcludedTags:        append(append([]Tag{}, p.ExcludedTags...), other.ExcludedTags...),
+	}
+}
+
+func (p *DietaryProfile) allergens() []Allergen {
+	allergens := append([]Allergen{}, p.Allergens...)
+	if p.GlutenFree {
+		allergens = append(allergens, AllergenGluten)
+	}
+	if p.LactoseFree {
+		allergens = append(allergens, AllergenLactose)
+	}
+	return allergens
+}
+
+// Excludes returns why the profile rules out recipe, or an empty string if
+// it does not. Allergens that can't be checked against the ingredients of
+// a recipe without any only pass if the recipe is tagged as free from them,
+// e.g. diet:gluten-free. A recipe is only vegetarian when tagged
+// diet:vegetarian or diet:vegan, as there are too many kinds of meat to
+// tell from the names of its ingredients.
+func (p *DietaryProfile) Excludes(recipe *Recipe) string {
+	for _, tag := range p.ExcludedTags {
+		if recipe.HasTag(tag) {
+			return fmt.Sprintf("tagged %s", tag)
+		}
+	}
+	for _, item := range recipe.Items {
+		for _, ingredient := range p.ExcludedIngredients {
+			if containsAny(item.Name, []string{strings.ToLower(strings.TrimSpace(ingredient))}) {
+				return fmt.Sprintf("contains %s", item.Name)
+			}
+		}
+	}
+	known := len(recipe.Items) > 0
+	contained := recipe.Allergens()
+	for _, allergen := range p.allergens() {
+		for _, a := range contained {
+			if a == allergen {
+				return fmt.Sprintf("contains %s", allergen)
+			}
+		}
+		if !known && !recipe.HasTag(Tag{TagDiet, string(allergen) + "-free"}) {
+			return fmt.Sprintf("not known to be %s free", allergen)
+		}
+	}
+	if p.Vegetarian {
+		for _, item := range recipe.Items {
+			if containsAny(item.Name, meatKeywords) ||
+				containsAny(item.Name, allergenKeywords[AllergenFish]) ||
+				containsAny(item.Name, allergenKeywords[AllergenShellfish]) {
+				return fmt.Sprintf("contains %s", item.Name)
+			}
+		}
+		if !recipe.HasTag(Tag{TagDiet, "vegetarian"}) && !recipe.HasTag(Tag{TagDiet, "vegan"}) {
+			return "not tagged diet:vegetarian"
+		}
+	}
+	return ""
+}
+
+// Filter returns the recipes the profile allows. excluded holds the reason
+// for every recipe left out, by recipe name. The error is
+// ErrAllRecipesExcluded if recipes is not empty but none are allowed.
+func (p *DietaryProfile) Filter(recipes []*Recipe) (allowed []*Recipe, excluded map[string]string, err error) {
+	allowed = []*Recipe{}
+	excluded = map[string]string{}
+	for _, recipe := range recipes {
+		if reason := p.Excludes(recipe); reason != "" {
+			excluded[recipe.Name] = reason
+			continue
+		}
+		allowed = append(allowed, recipe)
+	}
+	if len(recipes) > 0 && len(allowed) == 0 {
+		return allowed, excluded, ErrAllRecipesExcluded
+	}
+	return allowed, excluded, nil
+}
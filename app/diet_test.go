@@ -0,0 +1,106 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecipeAllergens(t *testing.T) {
+	recipe := &Recipe{NewRecipe: NewRecipe{
+		Name: "Pannkakor",
+		Items: []*Item{
+			{Name: "Vetemjöl"},
+			{Name: "Mjölk"},
+			{Name: "Ägg"},
+			{Name: "Sylt", Allergens: []Allergen{AllergenNuts}},
+		},
+	}}
+	expected := []Allergen{AllergenGluten, AllergenLactose, AllergenEgg, AllergenNuts}
+	if allergens := recipe.Allergens(); !reflect.DeepEqual(allergens, expected) {
+		t.Errorf("Expected allergens %v, got %v", expected, allergens)
+	}
+	if allergens := ItemAllergens(&Item{Name: "Champinjoner"}); len(allergens) != 0 {
+		t.Errorf("Expected no allergens in mushrooms, got %v", allergens)
+	}
+}
+
+func TestDietaryProfileExcludes(t *testing.T) {
+	carbonara := &Recipe{NewRecipe: NewRecipe{Name: "Carbonara", Items: []*Item{{Name: "Spaghetti"}, {Name: "Bacon"}, {Name: "Parmesan"}}}}
+	dal := &Recipe{NewRecipe: NewRecipe{Name: "Dal", Items: []*Item{{Name: "Röda linser"}, {Name: "Kokosmjölk"}}, Tags: []Tag{{TagDiet, "vegan"}}}}
+	untaggedDal := &Recipe{NewRecipe: NewRecipe{Name: "Untagged dal", Items: []*Item{{Name: "Röda linser"}, {Name: "Kokosmjölk"}}}}
+	soup := &Recipe{NewRecipe: NewRecipe{Name: "Soup"}}
+	taggedSoup := &Recipe{NewRecipe: NewRecipe{Name: "Tagged soup", Tags: []Tag{{TagDiet, "vegetarian"}, {TagDiet, "gluten-free"}}}}
+
+	tests := []struct {
+		profile  DietaryProfile
+		recipe   *Recipe
+		excluded bool
+	}{
+		{DietaryProfile{Vegetarian: true}, carbonara, true},
+		{DietaryProfile{Vegetarian: true}, dal, false},
+		// Ingredients without meat don't make a recipe vegetarian.
+		{DietaryProfile{Vegetarian: true}, untaggedDal, true},
+		{DietaryProfile{GlutenFree: true}, carbonara, true},
+		{DietaryProfile{GlutenFree: true}, dal, false},
+		// Kokosmjölk gives away lactose that is not there, rather that than
+		// the other way around.
+		{DietaryProfile{LactoseFree: true}, dal, true},
+		{DietaryProfile{Allergens: []Allergen{AllergenEgg}}, carbonara, false},
+		{DietaryProfile{ExcludedIngredients: []string{"linser"}}, dal, true},
+		{DietaryProfile{ExcludedTags: []Tag{{TagDiet, "vegetarian"}}}, taggedSoup, true},
+		{DietaryProfile{Vegetarian: true}, soup, true},
+		{DietaryProfile{Vegetarian: true, GlutenFree: true}, taggedSoup, false},
+		{DietaryProfile{}, soup, false},
+	}
+	for i, test := range tests {
+		reason := test.profile.Excludes(test.recipe)
+		if (reason != "") != test.excluded {
+			t.Errorf("%d: expected %s to be excluded: %v, got %q", i, test.recipe.Name, test.excluded, reason)
+		}
+	}
+}
+
+func TestVegetarianExcludesMeat(t *testing.T) {
+	vegetarian := &DietaryProfile{Vegetarian: true}
+	names := []string{
+		"Ham", "Parmaham", "Steak", "Veal", "Kalvfärs", "Biff", "Oxfilé", "Entrecôte", "Prosciutto",
+		"Lever", "Gelatin", "Anchovies", "Ansjovis", "Fiskbuljong", "Hönsbuljong",
+	}
+	for _, name := range names {
+		// Even when tagged by mistake.
+		recipe := &Recipe{NewRecipe: NewRecipe{Name: name, Items: []*Item{{Name: name}}, Tags: []Tag{{TagDiet, "vegetarian"}}}}
+		if vegetarian.Excludes(recipe) == "" {
+			t.Errorf("Expected %s not to be vegetarian", name)
+		}
+	}
+
+	toast := &Recipe{NewRecipe: NewRecipe{Name: "Ham and cheese toast", Items: []*Item{{Name: "Bread"}, {Name: "Cheese"}, {Name: "Gammon"}}}}
+	if reason := vegetarian.Excludes(toast); reason != "not tagged diet:vegetarian" {
+		t.Errorf("Expected a recipe not tagged vegetarian to be excluded, got %q", reason)
+	}
+}
+
+func TestDietaryProfileFilter(t *testing.T) {
+	recipes := createSomeRecipes(3)
+	recipes[0].Items = []*Item{{Name: "Kycklingfilé"}}
+	recipes[1].Items = []*Item{{Name: "Halloumi"}}
+	recipes[2].Items = []*Item{{Name: "Tofu"}}
+	for _, recipe := range recipes {
+		recipe.Tags = []Tag{{TagDiet, "vegetarian"}}
+	}
+	profile := (&DietaryProfile{Vegetarian: true}).Merge(&DietaryProfile{LactoseFree: true})
+
+	allowed, excluded, err := profile.Filter(recipes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 1 || allowed[0] != recipes[2] || len(excluded) != 2 {
+		t.Errorf("Expected only the tofu recipe to be allowed, got %v, excluded %v", allowed, excluded)
+	}
+
+	profile.Allergens = []Allergen{AllergenSoy}
+	if _, _, err := profile.Filter(recipes); !errors.Is(err, ErrAllRecipesExcluded) {
+		t.Errorf("Expected every recipe to be excluded, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"math"
 	"time"
@@ -20,8 +21,18 @@ type NewUser struct {
 	Password string `json:"password,omitempty"`
 }
 
+// ErrUsernameTaken is returned for usernames another user already has.
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// UserUpdate holds the changes to a user, fields left nil are kept.
+type UserUpdate struct {
+	Name     *string `json:"name"`
+	Username *string `json:"username"`
+}
+
 type User struct {
-	Weeks []*Week `json:"weeks,omitempty"`
+	Weeks []*Week  `json:"weeks,omitempty"`
+	Role  UserRole `json:"role,omitempty"`
 	token string
 	NewUser
 	Entity
@@ -34,11 +45,21 @@ type NewRecipe struct {
 	Portions           int     `json:"portions,omitempty"`
 	URL                string  `json:"url,omitempty"`
 	LeftOverCompliance bool    `json:"left_over_compliance"`
+	// Slots are the meals the recipe suits, a recipe without slots is a
+	// dinner.
+	Slots []MealSlot `json:"slots,omitempty"`
+	Tags  []Tag      `json:"tags,omitempty"`
+	// Macros are the nutrients in a portion, entered by hand for recipes
+	// whose ingredients are not all in the nutrient table.
+	Macros *Nutrients `json:"macros,omitempty"`
 }
 
 type Recipe struct {
 	NewRecipe
 	Entity
+	// Nutrition is computed from the nutrient table when recipes are
+	// listed.
+	Nutrition *Nutrition `json:"nutrition,omitempty"`
 }
 
 type Item struct {
@@ -46,6 +67,11 @@ type Item struct {
 	Price  int     `json:"price,omitempty"`
 	Amount float64 `json:"amount,omitempty"`
 	Unit   string  `json:"unit,omitempty"`
+	// Section is the part of the store the item is found in.
+	Section string `json:"section,omitempty"`
+	// Allergens are those of the ingredient that its name does not give
+	// away, see ItemAllergens.
+	Allergens []Allergen `json:"allergens,omitempty"`
 	Entity
 }
 
@@ -53,19 +79,50 @@ type NewWeek struct {
 	Days   []*Day `json:"days,omitempty"`
 	Number int    `json:"number,omitempty"`
 	Year   int    `json:"year,omitempty"`
+	// Seed is the random seed the week was generated or shuffled with.
+	Seed int64 `json:"seed,omitempty"`
+	// Status defaults to accepted for weeks that are saved.
+	Status WeekStatus `json:"status,omitempty"`
+	// Name tells drafts apart.
+	Name string `json:"name,omitempty"`
 }
 
 type Week struct {
 	NewWeek
 	Entity
+	// Cost is the projected cost of the week, only set on generated weeks.
+	Cost *WeekCost `json:"cost,omitempty"`
+	// Goals tells how close a generated week came to the nutrition goals
+	// it was generated with.
+	Goals []*GoalResult `json:"goals,omitempty"`
 }
 
 type Day struct {
-	Date   time.Time `json:"date,omitempty"`
-	Dinner *Recipe   `json:"dinner,omitempty"`
+	Date  time.Time          `json:"date,omitempty"`
+	Meals map[MealSlot]*Meal `json:"meals,omitempty"`
 	Entity
 }
 
+// ErrUnknownRecipe is returned for days planned with a recipe the
+// household doesn't have.
+var ErrUnknownRecipe = errors.New("unknown recipe")
+
+// ErrWeekArchived is returned when changing an archived week, which is kept
+// as it was when another week replaced it.
+var ErrWeekArchived = errors.New("archived weeks can not be changed")
+
+// RecipeStats tells how often and when a recipe is eaten according to the
+// accepted weeks.
+type RecipeStats struct {
+	RecipeID uuid.UUID `json:"recipe_id"`
+	Name     string    `json:"name"`
+	// TimesEaten counts the meals of the recipe up to today, leftovers
+	// included.
+	TimesEaten  int        `json:"times_eaten"`
+	LastEaten   *time.Time `json:"last_eaten,omitempty"`
+	NextPlanned *time.Time `json:"next_planned,omitempty"`
+}
+
 type HTTPError struct {
 	Message string `json:"message,omitempty"`
 	Code    int    `json:"code,omitempty"`
@@ -90,20 +147,53 @@ type UserService interface {
 	Users() ([]*User, error)
 	CreateUser(u *NewUser, hash []byte) (*User, error)
 	DeleteUser(id string) error
-	UserWeeks(userID string, startWeek, skipWeek int) ([]*Week, error)
-	// SaveUserWeeks(userID string, weeks []*Week) error
-	ValidateUserToken(token string) (string, error)
-	SaveUserToken(userID string, token string) error
+	UpdateUser(id string, update *UserUpdate) (*User, error)
+	// SetUserPassword replaces the password hash of the user, ending every
+	// session of theirs but keepSessionID and revoking their API tokens.
+	SetUserPassword(id string, hash []byte, keepSessionID string) error
+	// ValidateUserToken returns the session the token belongs to,
+	// ErrSessionExpired if it has expired.
+	ValidateUserToken(token string) (*Session, error)
+	// CreateSession starts a session of the user on the device described by
+	// label, which token authenticates.
+	CreateSession(userID string, token string, label string) (*Session, error)
+	// Sessions returns the sessions of the user that have not expired.
+	Sessions(userID string) ([]*Session, error)
+	DeleteSession(userID string, id string) error
+	DeleteSessionByToken(token string) error
+	// CreateAPIToken stores a hash of token as an API token of the user.
+	CreateAPIToken(userID string, newToken *NewAPIToken, token string) (*APIToken, error)
+	APITokens(userID string) ([]*APIToken, error)
+	// ValidateAPIToken returns the API token and notes that it was used.
+	ValidateAPIToken(token string) (*APIToken, error)
+	DeleteAPIToken(userID string, id string) error
 	GetUserHash(userID string) ([]byte, error)
 	UserByUserName(username string) (*User, error)
-	GetUserToken(userID string) (string, error)
+	SetUserRole(userID string, role UserRole) error
+	// PlannerSettings returns the settings of the user, or the defaults if
+	// none have been saved.
+	PlannerSettings(userID string) (*PlannerSettings, error)
+	SavePlannerSettings(userID string, settings *PlannerSettings) error
+	// DietaryProfile returns the dietary restrictions of the user, an empty
+	// profile if none have been saved.
+	DietaryProfile(userID string) (*DietaryProfile, error)
+	SaveDietaryProfile(userID string, profile *DietaryProfile) error
 }
 type RecipeService interface {
 	// Recipe(id int) (*Recipe, error)
 	Recipes() ([]*Recipe, error)
-	CreateRecipe(rs *NewRecipe, userID string) (*Recipe, error)
-	UpdateRecipe(rs *Recipe) (*Recipe, error)
-	UserRecipes(userID string) ([]*Recipe, error)
+	CreateRecipe(rs *NewRecipe, householdID string) (*Recipe, error)
+	// UpdateRecipe replaces the fields of a recipe of the household, or of
+	// a shared recipe when householdID is empty. Shared recipes can't be
+	// changed through a household. Items and tags are only replaced when
+	// they are not nil. The error is sql.ErrNoRows if there is no such
+	// recipe.
+	UpdateRecipe(rs *Recipe, householdID string) (*Recipe, error)
+	// HouseholdRecipes returns the recipes of the household together with
+	// those shared by everyone.
+	HouseholdRecipes(householdID string) ([]*Recipe, error)
+	// Items returns the catalogue of every ingredient used in a recipe.
+	Items() ([]*Item, error)
 	// DeleteRecipe(id int) error
 	// UserRecipes(userID int) ([]*Recipe, error)
 	DeleteAllRecipes() error
@@ -116,27 +206,60 @@ type RecipeService interface {
 //		DeleteItem(id int) error
 //	}
 type WeekService interface {
-	Week(id string, userID string) (*Week, error)
-	Weeks(userID string, year int) ([]*Week, error)
-	CreateWeek(w *NewWeek, userID string) (*Week, error)
-	CreateWeeks(w []*NewWeek, userID string) ([]*Week, error)
-	DeleteWeek(id string, userID string) error
-	UpdateWeek(w *Week, userID string) (*Week, error)
-	UpdateWeeks(w []*Week, userID string) ([]*Week, error)
-	LastGeneratedWeek(userID string) (*Week, error)
-	DeleteWeeks(userID string, year int) error
-	NextWeekNumber(userID string) (int, error)
+	Week(id string, householdID string) (*Week, error)
+	// Weeks returns the accepted weeks of the year.
+	Weeks(householdID string, year int) ([]*Week, error)
+	ArchivedWeeks(householdID string, year int) ([]*Week, error)
+	CreateWeek(w *NewWeek, householdID string) (*Week, error)
+	// CreateWeeks stores the weeks, as accepted unless they have another
+	// status. Accepted weeks they replace are archived.
+	CreateWeeks(w []*NewWeek, householdID string) ([]*Week, error)
+	DeleteWeek(id string, householdID string) error
+	// UpdateWeek and UpdateWeeks replace the days, number, year and seed
+	// of weeks, keeping their status and name.
+	// UpdateWeek replaces the seed and days of the week. Only drafts are
+	// moved to the number and year of w, accepted weeks keep theirs.
+	// Returns ErrWeekArchived for archived weeks and an error wrapping
+	// sql.ErrNoRows if there is no such week.
+	UpdateWeek(w *Week, householdID string) (*Week, error)
+	// UpdateWeeks updates the weeks like UpdateWeek, all or none of them.
+	UpdateWeeks(w []*Week, householdID string) ([]*Week, error)
+	// Drafts returns the drafts of the household, the most recently
+	// generated first.
+	Drafts(householdID string) ([]*Week, error)
+	// Draft returns the draft with the name.
+	Draft(name string, householdID string) (*Week, error)
+	// LastGeneratedWeek returns the most recently generated draft.
+	LastGeneratedWeek(householdID string) (*Week, error)
+	DeleteWeeks(householdID string, year int) error
+	// NextWeek returns the year and iso week number following the latest
+	// accepted week, or those of today if no week is accepted yet.
+	NextWeek(householdID string, today time.Time) (year, number int, err error)
+	// AcceptDraft deletes the draft draftID and creates weeks from it as
+	// accepted in a single transaction. Accepted weeks they replace are
+	// archived.
+	AcceptDraft(draftID string, weeks []*NewWeek, householdID string) ([]*Week, error)
+	// RecipeStats returns the stats of every recipe of the household as
+	// of today.
+	RecipeStats(householdID string, today time.Time) ([]*RecipeStats, error)
+	RecipeStat(recipeID string, householdID string, today time.Time) (*RecipeStats, error)
 }
 
 func (r *Recipe) AlterPortions(portions int) *Recipe {
 	alteredItems := make([]*Item, len(r.Items))
 	for i, item := range r.Items {
 		alteredFraction := float64(portions) / float64(r.Portions)
+		amount := math.Round(item.Amount*alteredFraction*100) / 100
+		if quantity, err := item.Quantity(); err == nil {
+			amount = quantity.Scale(alteredFraction).Round().Amount
+		}
 		alteredItems[i] = &Item{
-			Name:   item.Name,
-			Price:  int(float64(item.Price) * alteredFraction),
-			Amount: item.Amount * alteredFraction,
-			Unit:   item.Unit,
+			Name:      item.Name,
+			Price:     int(float64(item.Price) * alteredFraction),
+			Amount:    amount,
+			Unit:      item.Unit,
+			Section:   item.Section,
+			Allergens: item.Allergens,
 		}
 	}
 	r.Items = alteredItems
@@ -178,69 +301,44 @@ func GenerateWeeks(startTime time.Time) []*Week {
 	now := startTime
 	year, week := now.ISOWeek()
 
-	for i := week; i <= 52; i++ {
+	for i := week; isoweek.Validate(year, i); i++ {
 		weeks = append(weeks, &Week{
 			NewWeek: NewWeek{
-				Days: GenerateDays(year, i),
+				Days:   GenerateDays(year, i),
+				Number: i,
+				Year:   year,
 			},
 		})
 	}
 	return weeks
 }
 
-type DayWithRecipeSelectionMetadata struct {
-	Day                                  *Day
-	DistanceInDaysToDayToSelectRecipeFor int
-}
-
-type RecipeWithSelectionMetadata struct {
-	Recipe          *Recipe
-	SelectionWeight float64
-}
-
-// todo: don't always pick the first recipe
-func PickRecipeForDay(dayToSelectRecipeFor *Day, context []*Day, allRecipes []*Recipe) *Recipe {
-	if len(allRecipes) == 0 {
-		panic("Cannot pick recipe from empty slice (allRecipes)")
-	}
-	daysWithMetadata := make([]*DayWithRecipeSelectionMetadata, len(context))
-	for i, day := range context {
-		daysWithMetadata[i] = newRecipeSelectionMetadataForDay(day, dayToSelectRecipeFor)
-	}
-
-	getSelectionWeightMultiplier := func(recipe *Recipe) float64 {
-		const MAX_DIST_DAYS = 5
-		for _, day := range daysWithMetadata {
-			if day.Day.Dinner == nil {
-				continue
-			}
-			if day.Day.Dinner.Name == recipe.Name && day.DistanceInDaysToDayToSelectRecipeFor <= MAX_DIST_DAYS {
-				return 0
-			}
-		}
-		return 1
-	}
-
-	recipesWithMetadata := make([]*RecipeWithSelectionMetadata, len(allRecipes))
-	for i, recipe := range allRecipes {
-		recipesWithMetadata[i] = &RecipeWithSelectionMetadata{
-			Recipe:          recipe,
-			SelectionWeight: getSelectionWeightMultiplier(recipe) * recipe.ProbabilityWeight,
-		}
+// GenerateHorizon generates count consecutive weeks starting with iso week
+// number of year, continuing into the following years when needed.
+func GenerateHorizon(year, number, count int) []*Week {
+	weeks := make([]*Week, 0, count)
+	start := isoweek.StartTime(year, number, time.UTC)
+	for len(weeks) < count {
+		weeks = append(weeks, GenerateWeeks(start)...)
+		start = isoweek.StartTime(start.Year()+1, 1, time.UTC)
 	}
-
-	selectedRecipe := PickRandom(recipesWithMetadata, func(recipe *RecipeWithSelectionMetadata) float64 {
-		return recipe.SelectionWeight
-	})
-	return selectedRecipe.Recipe
+	return weeks[:count]
 }
 
-func newRecipeSelectionMetadataForDay(day *Day, dayToSelectRecipeFor *Day) *DayWithRecipeSelectionMetadata {
-	value := &DayWithRecipeSelectionMetadata{
-		Day:                                  day,
-		DistanceInDaysToDayToSelectRecipeFor: GetAbsoluteTimeDifferenceInDays(day.Date, dayToSelectRecipeFor.Date),
+// SplitIntoWeeks groups days by the iso week they belong to, keeping the
+// order of days.
+func SplitIntoWeeks(days []*Day) []*NewWeek {
+	weeks := []*NewWeek{}
+	var current *NewWeek
+	for _, day := range days {
+		year, number := day.Date.ISOWeek()
+		if current == nil || current.Year != year || current.Number != number {
+			current = &NewWeek{Number: number, Year: year}
+			weeks = append(weeks, current)
+		}
+		current.Days = append(current.Days, day)
 	}
-	return value
+	return weeks
 }
 
 func GetAbsoluteTimeDifferenceInDays(a time.Time, b time.Time) int {
@@ -248,12 +346,3 @@ func GetAbsoluteTimeDifferenceInDays(a time.Time, b time.Time) int {
 	distanceInHours := math.Abs(difference.Hours())
 	return int(distanceInHours / 24)
 }
-
-func containsRecipe(recipes []*Recipe, target *Recipe) bool {
-	for _, recipe := range recipes {
-		if recipe.Name == target.Name {
-			return true
-		}
-	}
-	return false
-}
@@ -46,47 +46,6 @@ func TestGenerateDays(t *testing.T) {
 	}
 }
 
-func TestNoDuplicateRecipesInNearbyDays(t *testing.T) {
-	days := createSomeDays(500)
-	recipes := createSomeRecipes(500)
-
-	// arrange
-	for _, day := range days {
-		recipe := PickRecipeForDay(day, days, recipes)
-		day.Dinner = recipe
-	}
-
-	assertDinnerIsDifferent := func(day1, day2 *Day) {
-		if day1.Dinner == day2.Dinner {
-			t.Errorf("Expected dinner of nearby day to be different from dinner of today")
-		}
-	}
-
-	// assert
-	for i, day := range days {
-		if i > 1 {
-			assertDinnerIsDifferent(day, days[i-1])
-		}
-		if i < len(days)-1 {
-			assertDinnerIsDifferent(day, days[i+1])
-		}
-
-	}
-}
-
-func createSomeDays(count int) []*Day {
-	START_DATE := time.Now()
-
-	days := make([]*Day, count)
-	for i := range days {
-		days[i] = &Day{
-			Date: START_DATE.AddDate(0, 0, i),
-		}
-	}
-
-	return days
-}
-
 func TestGenerateWeeks(t *testing.T) {
 	seedTime, err := time.Parse("2006-01-02", "2023-12-14")
 	if err != nil {
@@ -175,6 +134,29 @@ func TestAlterRecipePortions(t *testing.T) {
 	}
 }
 
+func TestAlterRecipePortionsRounds(t *testing.T) {
+	recipe := &Recipe{
+		NewRecipe: NewRecipe{
+			Portions: 3,
+			Items: []*Item{
+				{Name: "Onion", Amount: 1, Unit: "st"},
+				{Name: "Salt", Amount: 1, Unit: "tsk"},
+				{Name: "Saffron", Amount: 1, Unit: "pinch"},
+			},
+		},
+	}
+	recipe = recipe.AlterPortions(1)
+	if recipe.Items[0].Amount != 0.5 {
+		t.Errorf("Expected half an onion, got %f", recipe.Items[0].Amount)
+	}
+	if recipe.Items[1].Amount != 0.25 {
+		t.Errorf("Expected 0.25 tsk salt, got %f", recipe.Items[1].Amount)
+	}
+	if recipe.Items[2].Amount != 0.33 {
+		t.Errorf("Expected unknown units to be rounded to hundredths, got %f", recipe.Items[2].Amount)
+	}
+}
+
 func TestAlterRecipePortionsEffectOnCost(t *testing.T) {
 	items := []*Item{
 		{Name: "Item 1", Price: 10, Amount: 10, Unit: "ml"},
@@ -217,3 +199,35 @@ func createSomeRecipes(count int) []*Recipe {
 	}
 	return recipes
 }
+
+func TestGenerateHorizon(t *testing.T) {
+	weeks := GenerateHorizon(2026, 52, 3)
+	expected := []string{"2026-52", "2026-53", "2027-1"}
+	if len(weeks) != len(expected) {
+		t.Fatalf("Expected %d weeks, got %d", len(expected), len(weeks))
+	}
+	for i, week := range weeks {
+		res := fmt.Sprintf("%d-%d", week.Year, week.Number)
+		if res != expected[i] {
+			t.Errorf("Expected week %s, got %s", expected[i], res)
+		}
+		year, number := week.Days[0].Date.ISOWeek()
+		if year != week.Year || number != week.Number {
+			t.Errorf("Expected days of week %s, got %d-%d", expected[i], year, number)
+		}
+	}
+}
+
+func TestSplitIntoWeeks(t *testing.T) {
+	days := append(GenerateDays(2023, 52), GenerateDays(2024, 1)...)
+	weeks := SplitIntoWeeks(days)
+	if len(weeks) != 2 {
+		t.Fatalf("Expected 2 weeks, got %d", len(weeks))
+	}
+	if weeks[0].Year != 2023 || weeks[0].Number != 52 || len(weeks[0].Days) != 7 {
+		t.Errorf("Expected 7 days of week 52 2023, got %d days of week %d %d", len(weeks[0].Days), weeks[0].Number, weeks[0].Year)
+	}
+	if weeks[1].Year != 2024 || weeks[1].Number != 1 || len(weeks[1].Days) != 7 {
+		t.Errorf("Expected 7 days of week 1 2024, got %d days of week %d %d", len(weeks[1].Days), weeks[1].Number, weeks[1].Year)
+	}
+}
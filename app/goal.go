@@ -0,0 +1,195 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NutrientNames lists the nutrients goals can be set for.
+var NutrientNames = []string{"kcal", "protein", "fat", "carbs", "fibre", "salt"}
+
+// Get returns the amount of the nutrient with the name, false if there is
+// no such nutrient.
+func (n Nutrients) Get(name string) (float64, bool) {
+	switch name {
+	case "kcal":
+		return n.Kcal, true
+	case "protein":
+		return n.Protein, true
+	case "fat":
+		return n.Fat, true
+	case "carbs":
+		return n.Carbs, true
+	case "fibre":
+		return n.Fibre, true
+	case "salt":
+		return n.Salt, true
+	}
+	return 0, false
+}
+
+// NutritionGoal is a target for the average of a nutrient over the meals
+// in a slot, e.g. at least 30 g protein per dinner.
+type NutritionGoal struct {
+	Nutrient string   `json:"nutrient"`
+	Slot     MealSlot `json:"slot"`
+	// AtMost is set when the average should stay below Target rather than
+	// reach it.
+	AtMost bool    `json:"at_most,omitempty"`
+	Target float64 `json:"target"`
+}
+
+// ParseNutritionGoal reads a goal written as [slot:]nutrient>=target or
+// [slot:]nutrient<=target, e.g. protein>=30 or lunch:kcal<=700. Goals
+// without a slot are about dinners.
+func ParseNutritionGoal(s string) (NutritionGoal, error) {
+	goal := NutritionGoal{Slot: MealDinner}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if slot, rest, ok := strings.Cut(s, ":"); ok {
+		goal.Slot = MealSlot(strings.TrimSpace(slot))
+		s = rest
+	}
+	nutrient, target, ok := strings.Cut(s, ">=")
+	if !ok {
+		nutrient, target, ok = strings.Cut(s, "<=")
+		goal.AtMost = true
+	}
+	if !ok {
+		return NutritionGoal{}, fmt.Errorf("goal must be formatted as [slot:]nutrient>=target or [slot:]nutrient<=target, got %q", s)
+	}
+	goal.Nutrient = strings.TrimSpace(nutrient)
+	if _, ok := (Nutrients{}).Get(goal.Nutrient); !ok {
+		return NutritionGoal{}, fmt.Errorf("goal nutrient need to be one of %v, got %q", NutrientNames, goal.Nutrient)
+	}
+	if !goal.Slot.Valid() {
+		return NutritionGoal{}, fmt.Errorf("goal slot need to be one of %v, got %q", MealSlots, goal.Slot)
+	}
+	var err error
+	goal.Target, err = strconv.ParseFloat(strings.TrimSpace(target), 64)
+	if err != nil || goal.Target <= 0 {
+		return NutritionGoal{}, fmt.Errorf("goal target must be a positive number, got %q", target)
+	}
+	return goal, nil
+}
+
+func (g NutritionGoal) String() string {
+	comparison := ">="
+	if g.AtMost {
+		comparison = "<="
+	}
+	return fmt.Sprintf("%s:%s%s%g", g.Slot, g.Nutrient, comparison, g.Target)
+}
+
+// average returns the average of the nutrient over the meals of days in the
+// slot that have nutrition, leftovers included as they are eaten too, and
+// how many meals that is.
+func (g NutritionGoal) average(days []*Day) (float64, int) {
+	total, meals := 0.0, 0
+	for _, meal := range mealsOf(days) {
+		if meal.slot != g.Slot || meal.meal.Recipe == nil || meal.meal.Recipe.Nutrition == nil {
+			continue
+		}
+		value, _ := meal.meal.Recipe.Nutrition.PerPortion.Get(g.Nutrient)
+		total += value
+		meals++
+	}
+	if meals == 0 {
+		return 0, 0
+	}
+	return total / float64(meals), meals
+}
+
+// miss returns how far average is from meeting the goal, as a fraction of
+// the target, 0 if it is met.
+func (g NutritionGoal) miss(average float64) float64 {
+	if g.AtMost {
+		return math.Max(0, average-g.Target) / g.Target
+	}
+	return math.Max(0, g.Target-average) / g.Target
+}
+
+// goalBoost is how much more likely a recipe is planned for each goal of
+// the slot it meets on its own.
+const goalBoost = 2.0
+
+// goalMultiplier returns how much more likely recipe is to be planned in
+// slot because it meets goals.
+func goalMultiplier(goals []NutritionGoal, recipe *Recipe, slot MealSlot) float64 {
+	if recipe.Nutrition == nil {
+		return 1
+	}
+	multiplier := 1.0
+	for _, goal := range goals {
+		value, _ := recipe.Nutrition.PerPortion.Get(goal.Nutrient)
+		if goal.Slot == slot && goal.miss(value) == 0 {
+			multiplier *= goalBoost
+		}
+	}
+	return multiplier
+}
+
+type goalConstraint struct {
+	goal NutritionGoal
+}
+
+// MeetsGoal requires the meals in the slot of the goal to meet it on
+// average, counting the recipes whose Nutrition is set. Every tenth of the
+// target the average misses by is a violation. Meant to be used with Soft,
+// as meeting a goal is rarely a must.
+func MeetsGoal(goal NutritionGoal) Constraint {
+	return &goalConstraint{goal: goal}
+}
+
+func (c *goalConstraint) Name() string {
+	return fmt.Sprintf("nutrition goal %s", c.goal)
+}
+
+func (c *goalConstraint) Hard() bool      { return true }
+func (c *goalConstraint) Weight() float64 { return 1 }
+
+func (c *goalConstraint) Violations(days, _ []*Day) int {
+	// The average is not known until every meal in the slot is planned.
+	for _, meal := range mealsOf(days) {
+		if meal.slot == c.goal.Slot && meal.meal.Recipe == nil {
+			return 0
+		}
+	}
+	average, meals := c.goal.average(days)
+	if meals == 0 {
+		return 0
+	}
+	return int(math.Ceil(c.goal.miss(average)*10 - 1e-9))
+}
+
+// GoalResult tells how close a planned week came to a nutrition goal.
+type GoalResult struct {
+	Goal    NutritionGoal `json:"goal"`
+	Average float64       `json:"average"`
+	// Meals is how many meals the average is over, those without
+	// nutrition are left out.
+	Meals int  `json:"meals"`
+	Met   bool `json:"met"`
+	// Miss is how far the average is from the target, as a fraction of it.
+	Miss float64 `json:"miss"`
+}
+
+func NewGoalResults(days []*Day, goals []NutritionGoal) []*GoalResult {
+	results := []*GoalResult{}
+	for _, goal := range goals {
+		average, meals := goal.average(days)
+		miss := 1.0
+		if meals > 0 {
+			miss = goal.miss(average)
+		}
+		results = append(results, &GoalResult{
+			Goal:    goal,
+			Average: math.Round(average*10) / 10,
+			Meals:   meals,
+			Met:     meals > 0 && miss == 0,
+			Miss:    math.Round(miss*100) / 100,
+		})
+	}
+	return results
+}
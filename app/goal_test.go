@@ -0,0 +1,97 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseNutritionGoal(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected NutritionGoal
+	}{
+		{"protein>=30", NutritionGoal{Nutrient: "protein", Slot: MealDinner, Target: 30}},
+		{" Lunch:kcal <= 700", NutritionGoal{Nutrient: "kcal", Slot: MealLunch, AtMost: true, Target: 700}},
+	}
+	for _, test := range tests {
+		goal, err := ParseNutritionGoal(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if goal != test.expected {
+			t.Errorf("Expected %q to parse as %+v, got %+v", test.in, test.expected, goal)
+		}
+	}
+	for _, in := range []string{"protein=30", "sugar>=10", "brunch:kcal<=700", "fat<=-1", "fat>=x"} {
+		if _, err := ParseNutritionGoal(in); err == nil {
+			t.Errorf("Expected %q not to parse", in)
+		}
+	}
+}
+
+func TestPortionNutritionFallsBackToMacros(t *testing.T) {
+	table := foodsForTest(t)
+	recipe := &Recipe{NewRecipe: NewRecipe{
+		Name:     "Gröt",
+		Portions: 1,
+		Items:    []*Item{{Name: "Vetemjöl", Amount: 100, Unit: "g"}, {Name: "Sylt", Amount: 1, Unit: "dl"}},
+		Macros:   &Nutrients{Kcal: 420, Protein: 11},
+	}}
+	if nutrition := recipe.PortionNutrition(table); nutrition == nil || !nutrition.Manual || nutrition.PerPortion.Kcal != 420 {
+		t.Errorf("Expected the macros to be used when an ingredient is missing, got %+v", nutrition)
+	}
+	recipe.Items = recipe.Items[:1]
+	if nutrition := recipe.PortionNutrition(table); nutrition == nil || nutrition.Manual || nutrition.PerPortion.Kcal != 341 {
+		t.Errorf("Expected the ingredients to be used when all are known, got %+v", nutrition)
+	}
+}
+
+func TestPlannerPrefersMeetingNutritionGoals(t *testing.T) {
+	recipes := createSomeRecipes(20)
+	for i, recipe := range recipes {
+		recipe.ProbabilityWeight = 1
+		protein := 10.0
+		if i < 8 {
+			protein = 40
+		}
+		recipe.Nutrition = &Nutrition{PerPortion: Nutrients{Protein: protein}}
+	}
+	goal := NutritionGoal{Nutrient: "protein", Slot: MealDinner, Target: 30}
+
+	planner := NewPlanner(NewRand(1), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays), Soft(MeetsGoal(goal), 1))
+	planner.Goals = []NutritionGoal{goal}
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := NewGoalResults(week.Days, []NutritionGoal{goal})
+	// Seven dinners average at least 30 g protein with five of the high
+	// protein recipes, random picks average about 22 g.
+	if !results[0].Met || results[0].Meals != 7 {
+		t.Errorf("Expected the goal to be met over 7 dinners, got %+v", results[0])
+	}
+}
+
+func TestGoalResults(t *testing.T) {
+	days := GenerateDays(2023, 36)
+	for _, day := range days {
+		day.ID = uuid.New()
+	}
+	lean := &Recipe{NewRecipe: NewRecipe{Name: "Lean"}, Nutrition: &Nutrition{PerPortion: Nutrients{Kcal: 500}}}
+	rich := &Recipe{NewRecipe: NewRecipe{Name: "Rich"}, Nutrition: &Nutrition{PerPortion: Nutrients{Kcal: 1100}}}
+	days[0].SetRecipe(MealDinner, lean)
+	days[1].SetRecipe(MealDinner, rich)
+	days[2].Meals = map[MealSlot]*Meal{MealDinner: {Recipe: rich, LeftoverOf: &days[1].ID}}
+	days[3].SetRecipe(MealDinner, &Recipe{NewRecipe: NewRecipe{Name: "Unknown"}})
+
+	results := NewGoalResults(days, []NutritionGoal{{Nutrient: "kcal", Slot: MealDinner, AtMost: true, Target: 700}})
+
+	// (500 + 1100 + 1100) / 3, 200 kcal over the target.
+	if result := results[0]; result.Met || result.Meals != 3 || result.Average != 900 || result.Miss != 0.29 {
+		t.Errorf("Expected 900 kcal on average over 3 meals, missing by 0.29, got %+v", result)
+	}
+	if violations := MeetsGoal(results[0].Goal).Violations(days, nil); violations != 3 {
+		t.Errorf("Expected 3 violations, got %d", violations)
+	}
+}
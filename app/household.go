@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrLastOwner is returned when a change would leave a household with
+	// members but without an owner.
+	ErrLastOwner = errors.New("a household needs at least one owner")
+	// ErrLastMember is returned when the only member of a household would
+	// leave it, which would leave what it owns without members.
+	ErrLastMember = errors.New("the last member can not leave the household")
+	// ErrNotMember is returned for users that are not members of the
+	// household.
+	ErrNotMember = errors.New("user is not a member of the household")
+	// ErrAlreadyMember is returned when inviting a user that is already a
+	// member of the household.
+	ErrAlreadyMember = errors.New("user is already a member of the household")
+	// ErrNoInvite is returned when the user has no invite to the household.
+	ErrNoInvite = errors.New("user has no invite to the household")
+)
+
+// HouseholdRole is what a member may do with what the household owns.
+type HouseholdRole string
+
+const (
+	// RoleOwner may also manage the members of the household.
+	RoleOwner  HouseholdRole = "owner"
+	RoleEditor HouseholdRole = "editor"
+	// RoleViewer may only read the recipes and weeks of the household.
+	RoleViewer HouseholdRole = "viewer"
+)
+
+var HouseholdRoles = []HouseholdRole{RoleOwner, RoleEditor, RoleViewer}
+
+func (r HouseholdRole) Valid() bool {
+	for _, role := range HouseholdRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEdit reports whether members with the role may change the recipes,
+// weeks and pantry of the household.
+func (r HouseholdRole) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// Household is a group of users that share recipes, weeks and a pantry.
+// Every user belongs to exactly one household, their own until they join
+// another.
+type Household struct {
+	Name    string    `json:"name"`
+	Members []*Member `json:"members"`
+	Entity
+}
+
+type Member struct {
+	HouseholdID uuid.UUID     `json:"household_id"`
+	UserID      uuid.UUID     `json:"user_id"`
+	Name        string        `json:"name,omitempty"`
+	Role        HouseholdRole `json:"role"`
+}
+
+// Invite asks a user to join a household with a role. The user becomes a
+// member once they accept it.
+type Invite struct {
+	HouseholdID   uuid.UUID     `json:"household_id"`
+	HouseholdName string        `json:"household_name,omitempty"`
+	UserID        uuid.UUID     `json:"user_id"`
+	Role          HouseholdRole `json:"role"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type HouseholdService interface {
+	// Membership returns the household membership of the user.
+	Membership(userID string) (*Member, error)
+	Household(id string) (*Household, error)
+	// InviteMember invites the user to join the household with role,
+	// replacing any earlier invite to it. Returns ErrAlreadyMember for
+	// members of the household.
+	InviteMember(householdID, userID string, role HouseholdRole) (*Invite, error)
+	// Invites returns the invites the user has not answered.
+	Invites(userID string) ([]*Invite, error)
+	// AcceptInvite moves the user into the household with the role of the
+	// invite. If the user was alone in their earlier household, what it
+	// owned moves along and it is deleted. Returns ErrNoInvite without an
+	// invite and ErrLastOwner if the user is the last owner of a household
+	// with other members.
+	AcceptInvite(householdID, userID string) (*Member, error)
+	// DeclineInvite deletes the invite, returning ErrNoInvite if there is
+	// none.
+	DeclineInvite(householdID, userID string) error
+	UpdateMember(householdID, userID string, role HouseholdRole) (*Member, error)
+	// RemoveMember moves the user out of the household into a new one of
+	// their own. Returns ErrLastMember if nobody else is left in the
+	// household.
+	RemoveMember(householdID, userID string) error
+}
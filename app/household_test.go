@@ -0,0 +1,44 @@
+package app
+
+import "testing"
+
+func TestHouseholdRoles(t *testing.T) {
+	tests := []struct {
+		role    HouseholdRole
+		valid   bool
+		canEdit bool
+	}{
+		{RoleOwner, true, true},
+		{RoleEditor, true, true},
+		{RoleViewer, true, false},
+		{"admin", false, false},
+		{"", false, false},
+	}
+	for _, test := range tests {
+		if valid := test.role.Valid(); valid != test.valid {
+			t.Errorf("Expected %q to be valid %t, got %t", test.role, test.valid, valid)
+		}
+		if canEdit := test.role.CanEdit(); canEdit != test.canEdit {
+			t.Errorf("Expected %q to be able to edit %t, got %t", test.role, test.canEdit, canEdit)
+		}
+	}
+}
+
+func TestMergedDietaryProfilesSuitEveryMember(t *testing.T) {
+	recipes := []*Recipe{
+		{NewRecipe: NewRecipe{Name: "Fiskgratäng", Items: []*Item{{Name: "Torsk"}, {Name: "Potatis"}}}},
+		{NewRecipe: NewRecipe{Name: "Pannkakor", Items: []*Item{{Name: "Vetemjöl"}, {Name: "Mjölk"}, {Name: "Ägg"}}}},
+		{NewRecipe: NewRecipe{Name: "Linsgryta", Items: []*Item{{Name: "Linser"}, {Name: "Krossade tomater"}}, Tags: []Tag{{TagDiet, "vegan"}}}},
+	}
+	profile := (&DietaryProfile{}).
+		Merge(&DietaryProfile{Vegetarian: true}).
+		Merge(&DietaryProfile{GlutenFree: true})
+
+	allowed, excluded, err := profile.Filter(recipes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 1 || allowed[0].Name != "Linsgryta" {
+		t.Errorf("Expected only Linsgryta to suit both members, got %v excluded %v", allowed, excluded)
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// MealSlot names one of the meals of a day.
+type MealSlot string
+
+const (
+	MealBreakfast MealSlot = "breakfast"
+	MealLunch     MealSlot = "lunch"
+	MealDinner    MealSlot = "dinner"
+	MealSnack     MealSlot = "snack"
+)
+
+// MealSlots lists every meal slot in the order they are shown.
+var MealSlots = []MealSlot{MealBreakfast, MealLunch, MealDinner, MealSnack}
+
+func (s MealSlot) Valid() bool {
+	for _, slot := range MealSlots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// Meal is what is eaten in one slot of a day.
+type Meal struct {
+	Recipe *Recipe `json:"recipe,omitempty"`
+	// LeftoverOf is the id of the earlier day whose meal in the same slot
+	// is eaten again as leftovers, nil when the meal is cooked that day.
+	LeftoverOf *uuid.UUID `json:"leftover_of,omitempty"`
+}
+
+// IsLeftover reports whether the meal is leftovers from an earlier day.
+func (m *Meal) IsLeftover() bool {
+	return m.LeftoverOf != nil
+}
+
+// Recipe returns the recipe planned for slot, nil if there is none.
+func (d *Day) Recipe(slot MealSlot) *Recipe {
+	if meal, ok := d.Meals[slot]; ok {
+		return meal.Recipe
+	}
+	return nil
+}
+
+// SetRecipe plans recipe for slot as a meal cooked that day.
+func (d *Day) SetRecipe(slot MealSlot, recipe *Recipe) {
+	if d.Meals == nil {
+		d.Meals = map[MealSlot]*Meal{}
+	}
+	d.Meals[slot] = &Meal{Recipe: recipe}
+}
+
+// Slots returns the slots of the day that have a meal, in the order of
+// MealSlots.
+func (d *Day) Slots() []MealSlot {
+	slots := []MealSlot{}
+	for _, slot := range MealSlots {
+		if _, ok := d.Meals[slot]; ok {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// UnmarshalJSON also reads days saved before meal slots existed, which only
+// have a dinner.
+func (d *Day) UnmarshalJSON(data []byte) error {
+	type day Day
+	legacy := struct {
+		*day
+		Dinner     *Recipe    `json:"dinner"`
+		LeftoverOf *uuid.UUID `json:"leftover_of"`
+	}{day: (*day)(d)}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if legacy.Dinner != nil && d.Meals[MealDinner] == nil {
+		if d.Meals == nil {
+			d.Meals = map[MealSlot]*Meal{}
+		}
+		d.Meals[MealDinner] = &Meal{
+			Recipe:     legacy.Dinner,
+			LeftoverOf: legacy.LeftoverOf,
+		}
+	}
+	return nil
+}
+
+// Suits reports whether the recipe can be planned for slot. Recipes without
+// any slots are dinners.
+func (r *Recipe) Suits(slot MealSlot) bool {
+	if len(r.Slots) == 0 {
+		return slot == MealDinner
+	}
+	for _, s := range r.Slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
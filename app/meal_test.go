@@ -0,0 +1,55 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDayReadsLegacyDinner(t *testing.T) {
+	data := `{"date":"2023-09-04T00:00:00Z","dinner":{"name":"Tacos"},"leftover_of":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","id":"6ba7b811-9dad-11d1-80b4-00c04fd430c8"}`
+	var day Day
+	if err := json.Unmarshal([]byte(data), &day); err != nil {
+		t.Fatal(err)
+	}
+	if day.ID.String() != "6ba7b811-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("Expected the id to be read, got %s", day.ID)
+	}
+	if day.Date.Format("2006-01-02") != "2023-09-04" {
+		t.Errorf("Expected the date to be read, got %s", day.Date)
+	}
+	dinner := day.Meals[MealDinner]
+	if dinner == nil || dinner.Recipe.Name != "Tacos" {
+		t.Fatalf("Expected tacos for dinner, got %+v", dinner)
+	}
+	if !dinner.IsLeftover() || dinner.LeftoverOf.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("Expected the dinner to be leftovers, got %v", dinner.LeftoverOf)
+	}
+}
+
+func TestDayRoundTrip(t *testing.T) {
+	day := &Day{}
+	day.SetRecipe(MealBreakfast, &Recipe{NewRecipe: NewRecipe{Name: "Porridge"}})
+	day.SetRecipe(MealDinner, &Recipe{NewRecipe: NewRecipe{Name: "Tacos"}})
+	data, err := json.Marshal(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read Day
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if read.Recipe(MealBreakfast).Name != "Porridge" || read.Recipe(MealDinner).Name != "Tacos" {
+		t.Errorf("Expected porridge and tacos, got %s", data)
+	}
+}
+
+func TestRecipeSuits(t *testing.T) {
+	dinner := &Recipe{}
+	if !dinner.Suits(MealDinner) || dinner.Suits(MealLunch) {
+		t.Errorf("Expected a recipe without slots to only suit dinner")
+	}
+	lunch := &Recipe{NewRecipe: NewRecipe{Slots: []MealSlot{MealLunch, MealDinner}}}
+	if !lunch.Suits(MealLunch) || !lunch.Suits(MealDinner) || lunch.Suits(MealBreakfast) {
+		t.Errorf("Expected the recipe to suit lunch and dinner only")
+	}
+}
@@ -0,0 +1,357 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nutrients holds energy in kcal and the rest in grams.
+type Nutrients struct {
+	Kcal    float64 `json:"kcal"`
+	Protein float64 `json:"protein"`
+	Fat     float64 `json:"fat"`
+	Carbs   float64 `json:"carbs"`
+	Fibre   float64 `json:"fibre"`
+	Salt    float64 `json:"salt"`
+}
+
+func (n Nutrients) Add(other Nutrients) Nutrients {
+	return Nutrients{
+		Kcal:    n.Kcal + other.Kcal,
+		Protein: n.Protein + other.Protein,
+		Fat:     n.Fat + other.Fat,
+		Carbs:   n.Carbs + other.Carbs,
+		Fibre:   n.Fibre + other.Fibre,
+		Salt:    n.Salt + other.Salt,
+	}
+}
+
+func (n Nutrients) Scale(factor float64) Nutrients {
+	return Nutrients{
+		Kcal:    n.Kcal * factor,
+		Protein: n.Protein * factor,
+		Fat:     n.Fat * factor,
+		Carbs:   n.Carbs * factor,
+		Fibre:   n.Fibre * factor,
+		Salt:    n.Salt * factor,
+	}
+}
+
+// Round rounds energy to whole kcal and the rest to tenths of grams.
+func (n Nutrients) Round() Nutrients {
+	tenth := func(f float64) float64 { return math.Round(f*10) / 10 }
+	return Nutrients{
+		Kcal:    math.Round(n.Kcal),
+		Protein: tenth(n.Protein),
+		Fat:     tenth(n.Fat),
+		Carbs:   tenth(n.Carbs),
+		Fibre:   tenth(n.Fibre),
+		Salt:    tenth(n.Salt),
+	}
+}
+
+// Food is an entry in a nutrient table, with the nutrients in 100 g of it.
+type Food struct {
+	Name    string    `json:"name"`
+	Per100g Nutrients `json:"per_100g"`
+}
+
+type NutrientService interface {
+	Foods() ([]*Food, error)
+	// ImportFoods adds foods to the nutrient table, replacing those with the
+	// same name, and returns how many were imported.
+	ImportFoods(foods []*Food) (int, error)
+}
+
+// NutrientTable looks up the nutrients of ingredients by name.
+type NutrientTable struct {
+	foods map[string]*Food
+	names []string
+}
+
+func NewNutrientTable(foods []*Food) *NutrientTable {
+	table := &NutrientTable{foods: map[string]*Food{}}
+	for _, food := range foods {
+		name := strings.ToLower(strings.TrimSpace(food.Name))
+		table.foods[name] = food
+		table.names = append(table.names, name)
+	}
+	sort.Strings(table.names)
+	return table
+}
+
+// Lookup returns the food named as the ingredient. Failing that it returns
+// the shortest name that starts with the name of the ingredient as a word,
+// so that mjölk finds "Mjölk fett 3%" in the Livsmedelsverket table.
+func (t *NutrientTable) Lookup(ingredient string) (*Food, bool) {
+	name := strings.ToLower(strings.TrimSpace(ingredient))
+	if name == "" {
+		return nil, false
+	}
+	if food, ok := t.foods[name]; ok {
+		return food, true
+	}
+	found := ""
+	i := sort.SearchStrings(t.names, name)
+	for ; i < len(t.names) && strings.HasPrefix(t.names[i], name); i++ {
+		rest := t.names[i][len(name):]
+		if !strings.HasPrefix(rest, " ") && !strings.HasPrefix(rest, ",") {
+			continue
+		}
+		if found == "" || len(t.names[i]) < len(found) {
+			found = t.names[i]
+		}
+	}
+	if found == "" {
+		return nil, false
+	}
+	return t.foods[found], true
+}
+
+// pieceWeights holds the grams of one piece of ingredients that are counted
+// rather than weighed, by lower case name.
+var pieceWeights = map[string]float64{
+	"egg":           55,
+	"ägg":           55,
+	"onion":         100,
+	"lök":           100,
+	"gul lök":       100,
+	"rödlök":        100,
+	"vitlöksklyfta": 5,
+	"garlic clove":  5,
+	"tomato":        100,
+	"tomat":         100,
+	"potato":        100,
+	"potatis":       100,
+	"carrot":        70,
+	"morot":         70,
+	"lemon":         100,
+	"citron":        100,
+	"lime":          60,
+	"paprika":       150,
+	"avocado":       150,
+	"avokado":       150,
+}
+
+// itemGrams returns the weight of the amount of the item, false when it
+// can't be told.
+func itemGrams(item *Item) (float64, bool) {
+	quantity, err := item.Quantity()
+	if err != nil {
+		return 0, false
+	}
+	switch quantity.Dimension() {
+	case DimensionMass:
+		return quantity.Base().Amount, true
+	case DimensionVolume:
+		grams, err := quantity.ConvertFor(item.Name, "g")
+		if err != nil {
+			return 0, false
+		}
+		return grams.Amount, true
+	case DimensionCount:
+		weight, ok := pieceWeights[strings.ToLower(strings.TrimSpace(item.Name))]
+		return quantity.Amount * weight, ok
+	}
+	return 0, false
+}
+
+// Nutrition is the nutrients in a portion of a recipe. Missing lists the
+// ingredients that are left out of it because they are not in the nutrient
+// table or their weight is not known.
+type Nutrition struct {
+	PerPortion Nutrients `json:"per_portion"`
+	Missing    []string  `json:"missing,omitempty"`
+	// Manual is set when the nutrients are the macros entered on the
+	// recipe.
+	Manual bool `json:"manual,omitempty"`
+}
+
+// ComputeNutrition returns the nutrients in a portion of the recipe, nil if
+// none of its ingredients are in the table. Recipes without portions count
+// as one portion.
+func (r *Recipe) ComputeNutrition(table *NutrientTable) *Nutrition {
+	total := Nutrients{}
+	missing := []string{}
+	for _, item := range r.Items {
+		food, ok := table.Lookup(item.Name)
+		if !ok {
+			missing = append(missing, item.Name)
+			continue
+		}
+		grams, ok := itemGrams(item)
+		if !ok {
+			missing = append(missing, item.Name)
+			continue
+		}
+		total = total.Add(food.Per100g.Scale(grams / 100))
+	}
+	if len(missing) == len(r.Items) {
+		return nil
+	}
+	portions := r.Portions
+	if portions < 1 {
+		portions = 1
+	}
+	nutrition := &Nutrition{PerPortion: total.Scale(1 / float64(portions)).Round()}
+	if len(missing) > 0 {
+		nutrition.Missing = missing
+	}
+	return nutrition
+}
+
+// PortionNutrition returns the nutrients in a portion of the recipe. They
+// are computed from the ingredients when all of them are in the table, or
+// else taken from the macros entered on the recipe. Without macros as much
+// as can be computed is returned, nil if nothing can.
+func (r *Recipe) PortionNutrition(table *NutrientTable) *Nutrition {
+	computed := r.ComputeNutrition(table)
+	if computed != nil && len(computed.Missing) == 0 {
+		return computed
+	}
+	if r.Macros != nil {
+		return &Nutrition{PerPortion: *r.Macros, Manual: true}
+	}
+	return computed
+}
+
+type DayNutrition struct {
+	Date      time.Time `json:"date"`
+	Nutrients Nutrients `json:"nutrients"`
+}
+
+// WeekNutrition is what one person eats during a planned week, a portion of
+// every meal including leftovers.
+type WeekNutrition struct {
+	Days         []*DayNutrition `json:"days"`
+	Total        Nutrients       `json:"total"`
+	DailyAverage Nutrients       `json:"daily_average"`
+	// Missing lists the recipes that are left out, or only partly counted,
+	// because their ingredients are not in the nutrient table and they have
+	// no macros.
+	Missing []string `json:"missing,omitempty"`
+}
+
+func NewWeekNutrition(days []*Day, table *NutrientTable) *WeekNutrition {
+	summary := &WeekNutrition{Days: []*DayNutrition{}}
+	computed := map[*Recipe]*Nutrition{}
+	for _, day := range days {
+		dayNutrition := &DayNutrition{Date: day.Date}
+		for _, slot := range day.Slots() {
+			recipe := day.Recipe(slot)
+			if recipe == nil {
+				continue
+			}
+			nutrition, ok := computed[recipe]
+			if !ok {
+				nutrition = recipe.PortionNutrition(table)
+				computed[recipe] = nutrition
+				if nutrition == nil || len(nutrition.Missing) > 0 {
+					summary.Missing = appendUnique(summary.Missing, recipe.Name)
+				}
+			}
+			if nutrition != nil {
+				dayNutrition.Nutrients = dayNutrition.Nutrients.Add(nutrition.PerPortion)
+			}
+		}
+		summary.Total = summary.Total.Add(dayNutrition.Nutrients)
+		dayNutrition.Nutrients = dayNutrition.Nutrients.Round()
+		summary.Days = append(summary.Days, dayNutrition)
+	}
+	if len(days) > 0 {
+		summary.DailyAverage = summary.Total.Scale(1 / float64(len(days))).Round()
+	}
+	summary.Total = summary.Total.Round()
+	return summary
+}
+
+// ErrNoFoodNames is returned by ParseFoodsCSV when the header has no column
+// with the name of the foods.
+var ErrNoFoodNames = errors.New("no column with food names")
+
+// ParseFoodsCSV reads a nutrient table with a header row naming the
+// columns, in English or as in the Livsmedelsverket export, e.g.
+// Livsmedelsnamn;Energi (kcal);Protein (g);Fett, totalt (g);Kolhydrater,
+// tillgängliga (g);Fibrer (g);Salt, NaCl (g). Columns are separated by
+// semicolons or commas and decimals may use a comma. Unknown columns are
+// ignored and empty values read as 0.
+func ParseFoodsCSV(r io.Reader) ([]*Food, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	firstLine, _, _ := strings.Cut(string(data), "\n")
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	nameColumn := -1
+	columns := map[int]*float64{}
+	var food Food
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		switch {
+		case column == "name" || column == "namn" || column == "livsmedelsnamn" || column == "food":
+			nameColumn = i
+		case strings.Contains(column, "kcal"):
+			columns[i] = &food.Per100g.Kcal
+		case strings.HasPrefix(column, "protein"):
+			columns[i] = &food.Per100g.Protein
+		case strings.HasPrefix(column, "fett") || strings.HasPrefix(column, "fat"):
+			columns[i] = &food.Per100g.Fat
+		case strings.HasPrefix(column, "kolhydrat") || strings.HasPrefix(column, "carb"):
+			columns[i] = &food.Per100g.Carbs
+		case strings.HasPrefix(column, "fib"):
+			columns[i] = &food.Per100g.Fibre
+		case strings.HasPrefix(column, "salt"):
+			columns[i] = &food.Per100g.Salt
+		}
+	}
+	if nameColumn < 0 {
+		return nil, ErrNoFoodNames
+	}
+
+	foods := []*Food{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if nameColumn >= len(record) || strings.TrimSpace(record[nameColumn]) == "" {
+			continue
+		}
+		food = Food{Name: strings.TrimSpace(record[nameColumn])}
+		for i, field := range columns {
+			if i >= len(record) {
+				continue
+			}
+			value := strings.ReplaceAll(strings.TrimSpace(record[i]), ",", ".")
+			if value == "" {
+				continue
+			}
+			*field, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %q is not a number", line, record[i])
+			}
+		}
+		parsed := food
+		foods = append(foods, &parsed)
+	}
+	return foods, nil
+}
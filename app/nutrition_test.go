@@ -0,0 +1,107 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+const livsmedelsverket = `Livsmedelsnamn;Livsmedelsnummer;Energi (kcal);Energi (kJ);Protein (g);Fett, totalt (g);Kolhydrater, tillgängliga (g);Fibrer (g);Salt, NaCl (g)
+Vetemjöl;1;341;1443;10,9;1,8;67,3;3,9;0
+Mjölk fett 3%;2;60;251;3,4;3;4,8;0;0,1
+Mjölk fett 0,5%;3;36;152;3,5;0,5;4,8;0;0,1
+Ägg;4;139;579;12,5;9,7;0,3;0;0,3
+`
+
+func foodsForTest(t *testing.T) *NutrientTable {
+	foods, err := ParseFoodsCSV(strings.NewReader(livsmedelsverket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewNutrientTable(foods)
+}
+
+func TestParseFoodsCSV(t *testing.T) {
+	foods, err := ParseFoodsCSV(strings.NewReader(livsmedelsverket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foods) != 4 {
+		t.Fatalf("Expected 4 foods, got %d", len(foods))
+	}
+	expected := Nutrients{Kcal: 341, Protein: 10.9, Fat: 1.8, Carbs: 67.3, Fibre: 3.9}
+	if foods[0].Name != "Vetemjöl" || foods[0].Per100g != expected {
+		t.Errorf("Expected flour with %+v, got %+v", expected, foods[0])
+	}
+
+	foods, err = ParseFoodsCSV(strings.NewReader("name,kcal,protein\nTofu,120,12.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foods[0].Per100g != (Nutrients{Kcal: 120, Protein: 12.5}) {
+		t.Errorf("Expected tofu from an English table, got %+v", foods[0])
+	}
+	if _, err := ParseFoodsCSV(strings.NewReader("kcal,protein\n1,2\n")); err != ErrNoFoodNames {
+		t.Errorf("Expected a table without names to be rejected, got %v", err)
+	}
+}
+
+func TestNutrientTableLookup(t *testing.T) {
+	table := foodsForTest(t)
+	if food, ok := table.Lookup("mjölk"); !ok || food.Name != "Mjölk fett 3%" {
+		t.Errorf("Expected milk to find the shortest milk, got %v", food)
+	}
+	if _, ok := table.Lookup("Mjöl"); ok {
+		t.Errorf("Expected mjöl not to find mjölk")
+	}
+}
+
+func TestRecipeNutrition(t *testing.T) {
+	recipe := &Recipe{NewRecipe: NewRecipe{
+		Name:     "Pannkakor",
+		Portions: 2,
+		Items: []*Item{
+			{Name: "Vetemjöl", Amount: 100, Unit: "g"},
+			{Name: "Mjölk", Amount: 2, Unit: "dl"},
+			{Name: "Ägg", Amount: 2, Unit: "st"},
+			{Name: "Sylt", Amount: 1, Unit: "dl"},
+		},
+	}}
+	nutrition := recipe.ComputeNutrition(foodsForTest(t))
+	if nutrition == nil {
+		t.Fatal("Expected nutrition")
+	}
+	// 341 + 206 g milk at 60 + 110 g egg at 139, over two portions.
+	if nutrition.PerPortion.Kcal != 309 {
+		t.Errorf("Expected 309 kcal per portion, got %v", nutrition.PerPortion.Kcal)
+	}
+	if len(nutrition.Missing) != 1 || nutrition.Missing[0] != "Sylt" {
+		t.Errorf("Expected jam to be missing, got %v", nutrition.Missing)
+	}
+	if (&Recipe{NewRecipe: NewRecipe{Name: "Soup"}}).ComputeNutrition(foodsForTest(t)) != nil {
+		t.Errorf("Expected no nutrition for a recipe without ingredients")
+	}
+}
+
+func TestWeekNutrition(t *testing.T) {
+	recipe := &Recipe{NewRecipe: NewRecipe{
+		Name:     "Gröt",
+		Portions: 1,
+		Items:    []*Item{{Name: "Vetemjöl", Amount: 100, Unit: "g"}},
+	}}
+	days := GenerateDays(2023, 36)
+	days[0].SetRecipe(MealBreakfast, recipe)
+	days[0].SetRecipe(MealDinner, recipe)
+	days[1].SetRecipe(MealDinner, &Recipe{NewRecipe: NewRecipe{Name: "Soup"}})
+
+	summary := NewWeekNutrition(days, foodsForTest(t))
+
+	if summary.Days[0].Nutrients.Kcal != 682 || summary.Total.Kcal != 682 {
+		t.Errorf("Expected 682 kcal on monday and in total, got %v and %v", summary.Days[0].Nutrients.Kcal, summary.Total.Kcal)
+	}
+	if summary.DailyAverage.Kcal != 97 {
+		t.Errorf("Expected a daily average of 97 kcal, got %v", summary.DailyAverage.Kcal)
+	}
+	if len(summary.Missing) != 1 || summary.Missing[0] != "Soup" {
+		t.Errorf("Expected the soup to be missing, got %v", summary.Missing)
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// ExpiringWithinDays is how close to its best before date a pantry item has
+// to be for the planner to favour recipes that use it.
+const ExpiringWithinDays = 3
+
+// expiryBoost is how much the weight of a recipe grows for every expiring
+// pantry item it uses.
+const expiryBoost = 2.0
+
+type NewPantryItem struct {
+	Name   string  `json:"name,omitempty"`
+	Amount float64 `json:"amount,omitempty"`
+	Unit   string  `json:"unit,omitempty"`
+	// BestBefore is nil for items that keep.
+	BestBefore *time.Time `json:"best_before,omitempty"`
+}
+
+// PantryItem is an ingredient the household already has at home.
+type PantryItem struct {
+	NewPantryItem
+	Entity
+}
+
+type PantryService interface {
+	PantryItems(householdID string) ([]*PantryItem, error)
+	CreatePantryItem(p *NewPantryItem, householdID string) (*PantryItem, error)
+	UpdatePantryItem(p *PantryItem, householdID string) (*PantryItem, error)
+	DeletePantryItem(id string, householdID string) error
+}
+
+// Expired reports whether the item is past its best before date at.
+func (p *PantryItem) Expired(at time.Time) bool {
+	return p.BestBefore != nil && p.BestBefore.Before(at)
+}
+
+// Expiring reports whether the item reaches its best before date within
+// ExpiringWithinDays of at, without being past it.
+func (p *PantryItem) Expiring(at time.Time) bool {
+	return p.BestBefore != nil && !p.Expired(at) &&
+		p.BestBefore.Sub(at) <= ExpiringWithinDays*24*time.Hour
+}
+
+func (p *PantryItem) Quantity() (Quantity, error) {
+	return NewQuantity(p.Amount, p.Unit)
+}
+
+// expiryMultiplier returns how much more likely recipe is to be planned for
+// a meal at date because it uses pantry items close to expiring.
+func expiryMultiplier(pantry []*PantryItem, recipe *Recipe, at time.Time) float64 {
+	multiplier := 1.0
+	for _, item := range recipe.Items {
+		for _, stock := range pantry {
+			if strings.EqualFold(strings.TrimSpace(stock.Name), strings.TrimSpace(item.Name)) && stock.Expiring(at) {
+				multiplier += expiryBoost
+				break
+			}
+		}
+	}
+	return multiplier
+}
+
+// SubtractPantry removes what is already in the pantry from the list. Items
+// past their best before date at are not counted. Lines covered by the
+// pantry are removed and the cost of the rest lowered by the share that is
+// already at home.
+func (l *ShoppingList) SubtractPantry(pantry []*PantryItem, at time.Time) {
+	remaining := map[*PantryItem]float64{}
+	for _, stock := range pantry {
+		remaining[stock] = stock.Amount
+	}
+	l.Cost = 0
+	l.ItemCount = 0
+	sections := []*ShoppingSection{}
+	for _, section := range l.Sections {
+		items := []*ShoppingItem{}
+		section.Cost = 0
+		for _, line := range section.Items {
+			needed := line.Amount
+			for _, stock := range pantry {
+				if line.Amount <= 0 {
+					break
+				}
+				if stock.Expired(at) || remaining[stock] <= 0 || !strings.EqualFold(strings.TrimSpace(stock.Name), line.Name) {
+					continue
+				}
+				available, ok := stockIn(stock, remaining[stock], line)
+				if !ok {
+					continue
+				}
+				used := math.Min(available, line.Amount)
+				line.Amount -= used
+				line.InPantry += used
+				remaining[stock] -= remaining[stock] * used / available
+			}
+			if line.InPantry > 0 && line.Amount <= 1e-9 {
+				continue
+			}
+			if needed > 0 {
+				line.Cost *= line.Amount / needed
+			}
+			items = append(items, line)
+			section.Cost += line.Cost
+		}
+		if len(items) == 0 {
+			continue
+		}
+		section.Items = items
+		sections = append(sections, section)
+		l.Cost += section.Cost
+		l.ItemCount += len(items)
+	}
+	l.Sections = sections
+}
+
+// stockIn returns amount of stock in the unit of line, false if the units
+// can not be converted.
+func stockIn(stock *PantryItem, amount float64, line *ShoppingItem) (float64, bool) {
+	if strings.EqualFold(strings.TrimSpace(stock.Unit), line.Unit) {
+		return amount, true
+	}
+	quantity, err := NewQuantity(amount, stock.Unit)
+	if err != nil {
+		return 0, false
+	}
+	converted, err := quantity.ConvertFor(stock.Name, line.Unit)
+	if err != nil {
+		return 0, false
+	}
+	return converted.Amount, true
+}
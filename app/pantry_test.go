@@ -0,0 +1,105 @@
+package app
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func pantryItem(name string, amount float64, unit string, bestBefore *time.Time) *PantryItem {
+	return &PantryItem{NewPantryItem: NewPantryItem{Name: name, Amount: amount, Unit: unit, BestBefore: bestBefore}}
+}
+
+func TestPantryItemExpiry(t *testing.T) {
+	now := time.Date(2023, 9, 4, 0, 0, 0, 0, time.UTC)
+	soon := now.AddDate(0, 0, 2)
+	later := now.AddDate(0, 0, 10)
+	past := now.AddDate(0, 0, -1)
+
+	if !pantryItem("Milk", 1, "l", &soon).Expiring(now) {
+		t.Errorf("Expected milk best before in two days to be expiring")
+	}
+	if pantryItem("Milk", 1, "l", &later).Expiring(now) {
+		t.Errorf("Expected milk best before in ten days not to be expiring")
+	}
+	if expired := pantryItem("Milk", 1, "l", &past); !expired.Expired(now) || expired.Expiring(now) {
+		t.Errorf("Expected milk past its best before date to be expired, not expiring")
+	}
+	if pantryItem("Salt", 1, "kg", nil).Expiring(now) {
+		t.Errorf("Expected items without best before date never to expire")
+	}
+}
+
+func TestShoppingListSubtractsPantry(t *testing.T) {
+	recipe := &Recipe{NewRecipe: NewRecipe{
+		Name: "Pancakes",
+		Items: []*Item{
+			{Name: "Milk", Amount: 6, Unit: "dl", Price: 12},
+			{Name: "Vetemjöl", Amount: 3, Unit: "dl", Price: 3},
+			{Name: "Egg", Amount: 3, Unit: "st", Price: 9},
+		},
+	}}
+	days := GenerateDays(2023, 36)
+	for _, day := range days {
+		day.ID = uuid.New()
+	}
+	days[0].SetRecipe(MealDinner, recipe)
+	now := days[0].Date
+	past := now.AddDate(0, 0, -1)
+
+	list := NewShoppingList(days, 0)
+	list.SubtractPantry([]*PantryItem{
+		pantryItem("milk", 2, "dl", nil),
+		pantryItem("Vetemjöl", 1, "kg", nil),
+		pantryItem("Egg", 6, "st", &past),
+	}, now)
+
+	lines := map[string]*ShoppingItem{}
+	for _, section := range list.Sections {
+		for _, line := range section.Items {
+			lines[line.Name] = line
+		}
+	}
+	if milk := lines["Milk"]; milk == nil || math.Abs(milk.Amount-4) > 1e-9 || milk.InPantry != 2 || math.Abs(milk.Cost-8) > 1e-9 {
+		t.Errorf("Expected 4 dl milk for 8 left to buy, got %+v", milk)
+	}
+	if _, ok := lines["Vetemjöl"]; ok {
+		t.Errorf("Expected flour in the pantry to be left out of the list")
+	}
+	if egg := lines["Egg"]; egg == nil || egg.Amount != 3 {
+		t.Errorf("Expected expired eggs not to be counted, got %+v", egg)
+	}
+	if list.ItemCount != 2 || math.Abs(list.Cost-17) > 1e-9 {
+		t.Errorf("Expected 2 items for 17, got %d for %v", list.ItemCount, list.Cost)
+	}
+}
+
+func TestPlannerFavoursExpiringPantryItems(t *testing.T) {
+	recipes := createSomeRecipes(20)
+	for _, recipe := range recipes {
+		recipe.ProbabilityWeight = 1
+	}
+	recipes[0].Items = []*Item{{Name: "Spinach", Amount: 200, Unit: "g"}}
+	bestBefore := time.Date(2023, 9, 5, 0, 0, 0, 0, time.UTC)
+	pantry := []*PantryItem{pantryItem("Spinach", 200, "g", &bestBefore)}
+
+	picked := 0
+	for seed := int64(0); seed < 100; seed++ {
+		planner := NewPlanner(NewRand(seed), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+		planner.Pantry = pantry
+		week, err := planner.PlanWeek(2023, 36)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if week.Days[0].Recipe(MealDinner) == recipes[0] {
+			picked++
+		}
+	}
+	// Without the pantry the recipe would be picked on monday about 5 times
+	// out of 100, three times as likely with it.
+	if picked < 10 {
+		t.Errorf("Expected the recipe using expiring spinach to be favoured, picked %d times", picked)
+	}
+}
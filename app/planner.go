@@ -0,0 +1,643 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultNoRepeatDays is how many days have to pass before the same recipe
+// may be planned again.
+const DefaultNoRepeatDays = 5
+
+// maxSearchSteps bounds how many recipe placements a single planning attempt
+// tries before giving up, so that an unsatisfiable set of constraints
+// can't make the search run forever.
+const maxSearchSteps = 5000
+
+// defaultAttempts is how many complete plans are sampled when soft
+// constraints are present.
+const defaultAttempts = 25
+
+var ErrEmptyRecipePool = errors.New("no recipes found to generate from")
+
+// ErrSearchExhausted is returned when the search gives up after
+// maxSearchSteps without finding a plan, which doesn't prove that there is
+// none.
+var ErrSearchExhausted = fmt.Errorf("no plan found within %d search steps, the constraints may be too strict", maxSearchSteps)
+
+// InfeasibleError is returned when no plan satisfies the hard constraints.
+// Constraint names the constraint that rejected the most placements.
+type InfeasibleError struct {
+	Constraint string `json:"constraint"`
+}
+
+func (e *InfeasibleError) Error() string {
+	if e.Constraint == "" {
+		return "no plan satisfies the constraints"
+	}
+	return fmt.Sprintf("no plan satisfies constraint %q", e.Constraint)
+}
+
+// RecipeMatcher selects the recipes a constraint is about, e.g. fish dishes.
+type RecipeMatcher func(*Recipe) bool
+
+// NameContains matches recipes whose name contains term, ignoring case.
+func NameContains(term string) RecipeMatcher {
+	term = strings.ToLower(term)
+	return func(r *Recipe) bool {
+		return strings.Contains(strings.ToLower(r.Name), term)
+	}
+}
+
+// Constraint is a rule a planned week is checked against.
+type Constraint interface {
+	// Name identifies the constraint in infeasibility reports.
+	Name() string
+	// Hard reports whether a plan breaking the constraint is rejected
+	// rather than just penalised.
+	Hard() bool
+	// Weight scales the penalty of a broken soft constraint.
+	Weight() float64
+	// Violations counts how many times days break the constraint. Meals
+	// without a recipe are yet to be planned and only count against the
+	// constraint once it can no longer be met. Leftovers are not a newly
+	// cooked meal and are not counted either. history holds earlier days
+	// that are not part of the plan.
+	Violations(days, history []*Day) int
+}
+
+// slotMeal is the meal in one slot of a day.
+type slotMeal struct {
+	day  *Day
+	slot MealSlot
+	meal *Meal
+}
+
+// cooked reports whether the meal has a recipe that is cooked that day.
+func (m slotMeal) cooked() bool {
+	return m.meal.Recipe != nil && !m.meal.IsLeftover()
+}
+
+// mealsOf returns every meal of days, in day and slot order.
+func mealsOf(days []*Day) []slotMeal {
+	meals := []slotMeal{}
+	for _, day := range days {
+		for _, slot := range day.Slots() {
+			meals = append(meals, slotMeal{day: day, slot: slot, meal: day.Meals[slot]})
+		}
+	}
+	return meals
+}
+
+type noRepeatConstraint struct {
+	days int
+}
+
+// NoRepeatWithin forbids planning the same recipe twice within days days,
+// including the days in the planner history.
+func NoRepeatWithin(days int) Constraint {
+	return &noRepeatConstraint{days: days}
+}
+
+func (c *noRepeatConstraint) Name() string {
+	return fmt.Sprintf("no repeat within %d days", c.days)
+}
+
+func (c *noRepeatConstraint) Hard() bool      { return true }
+func (c *noRepeatConstraint) Weight() float64 { return 1 }
+
+func (c *noRepeatConstraint) Violations(days, history []*Day) int {
+	violations := 0
+	tooClose := func(a, b slotMeal) bool {
+		return b.cooked() &&
+			a.meal.Recipe.Name == b.meal.Recipe.Name &&
+			GetAbsoluteTimeDifferenceInDays(a.day.Date, b.day.Date) <= c.days
+	}
+	planned, past := mealsOf(days), mealsOf(history)
+	for i, meal := range planned {
+		if !meal.cooked() {
+			continue
+		}
+		for _, other := range planned[i+1:] {
+			if tooClose(meal, other) {
+				violations++
+			}
+		}
+		for _, other := range past {
+			if tooClose(meal, other) {
+				violations++
+			}
+		}
+	}
+	return violations
+}
+
+type countConstraint struct {
+	name  string
+	min   int
+	max   int
+	match RecipeMatcher
+}
+
+// AtLeast requires at least n meals in the plan to match.
+func AtLeast(n int, name string, match RecipeMatcher) Constraint {
+	return &countConstraint{
+		name:  fmt.Sprintf("at least %d %s", n, name),
+		min:   n,
+		max:   -1,
+		match: match,
+	}
+}
+
+// AtMost allows at most n meals in the plan to match.
+func AtMost(n int, name string, match RecipeMatcher) Constraint {
+	return &countConstraint{
+		name:  fmt.Sprintf("at most %d %s", n, name),
+		min:   -1,
+		max:   n,
+		match: match,
+	}
+}
+
+func (c *countConstraint) Name() string    { return c.name }
+func (c *countConstraint) Hard() bool      { return true }
+func (c *countConstraint) Weight() float64 { return 1 }
+
+func (c *countConstraint) Violations(days, _ []*Day) int {
+	matched, open := 0, 0
+	for _, meal := range mealsOf(days) {
+		if meal.meal.Recipe == nil {
+			open++
+			continue
+		}
+		if meal.cooked() && c.match(meal.meal.Recipe) {
+			matched++
+		}
+	}
+	if c.min >= 0 && matched+open < c.min {
+		return c.min - matched - open
+	}
+	if c.max >= 0 && matched > c.max {
+		return matched - c.max
+	}
+	return 0
+}
+
+type weekdayConstraint struct {
+	name     string
+	weekdays []time.Weekday
+	match    RecipeMatcher
+}
+
+// OnWeekdays requires every meal cooked on one of weekdays to match,
+// e.g. only quick dishes Monday to Friday.
+func OnWeekdays(weekdays []time.Weekday, name string, match RecipeMatcher) Constraint {
+	return &weekdayConstraint{
+		name:     fmt.Sprintf("only %s on weekdays", name),
+		weekdays: weekdays,
+		match:    match,
+	}
+}
+
+func (c *weekdayConstraint) Name() string    { return c.name }
+func (c *weekdayConstraint) Hard() bool      { return true }
+func (c *weekdayConstraint) Weight() float64 { return 1 }
+
+func (c *weekdayConstraint) Violations(days, _ []*Day) int {
+	violations := 0
+	for _, meal := range mealsOf(days) {
+		if !meal.cooked() {
+			continue
+		}
+		for _, weekday := range c.weekdays {
+			if meal.day.Date.Weekday() == weekday && !c.match(meal.meal.Recipe) {
+				violations++
+			}
+		}
+	}
+	return violations
+}
+
+type softConstraint struct {
+	Constraint
+	weight float64
+}
+
+// Soft turns c into a preference. Plans breaking it are still accepted but
+// the planner favours the ones with the lowest total weight of broken soft
+// constraints.
+func Soft(c Constraint, weight float64) Constraint {
+	return &softConstraint{Constraint: c, weight: weight}
+}
+
+func (c *softConstraint) Hard() bool      { return false }
+func (c *softConstraint) Weight() float64 { return c.weight }
+
+// Planner fills the meal slots of days with recipes from a pool so that
+// every hard constraint holds, preferring plans that break as few soft
+// constraints as possible. Recipes are tried in a random order biased by
+// their probability weight, drawn from Rand so that the same seed and
+// input give the same plan.
+type Planner struct {
+	Rand        *rand.Rand
+	Recipes     []*Recipe
+	Constraints []Constraint
+	// History holds previously planned days that constraints such as
+	// NoRepeatWithin look back at.
+	History []*Day
+	// Slots are the meal slots planned for every day, only dinner when
+	// empty.
+	Slots []MealSlot
+	// Attempts is how many plans are sampled when soft constraints are
+	// present, the one with the lowest penalty wins.
+	Attempts int
+	// Decay lowers the weight of recipes eaten recently, in the history
+	// or elsewhere in the plan.
+	Decay RecencyDecay
+	// HouseholdPortions is how many portions the household eats a meal.
+	// When set, leftover compliant recipes with enough portions for
+	// another meal are followed by leftovers in the same slot the day
+	// after.
+	HouseholdPortions int
+	// Pantry makes recipes using pantry items close to their best before
+	// date more likely on the days before it.
+	Pantry []*PantryItem
+	// Goals make recipes that meet a nutrition goal on their own more
+	// likely in the slot of the goal. Add MeetsGoal constraints to also
+	// prefer the plans that meet them on average.
+	Goals []NutritionGoal
+
+	// uses counts how often each recipe was planned in earlier weeks of
+	// the horizon being planned by PlanWeeks.
+	uses map[string]int
+}
+
+func NewPlanner(rnd *rand.Rand, recipes []*Recipe, history []*Day, constraints ...Constraint) *Planner {
+	return &Planner{
+		Rand:        rnd,
+		Recipes:     recipes,
+		Constraints: constraints,
+		History:     history,
+		Attempts:    defaultAttempts,
+	}
+}
+
+// PlanWeek returns iso week number of year with every meal slot planned
+// for every day.
+func (p *Planner) PlanWeek(year, number int) (*Week, error) {
+	days := GenerateDays(year, number)
+	if err := p.Fill(days); err != nil {
+		return nil, err
+	}
+	return &Week{
+		NewWeek: NewWeek{
+			Days:   days,
+			Number: number,
+			Year:   year,
+		},
+	}, nil
+}
+
+// PlanWeeks fills every day of weeks, one week at a time. Each planned week
+// becomes history for the next, and recipes already used earlier in the
+// horizon are made less likely so that the weeks together follow the
+// probability weights instead of repeating the favourites every week.
+func (p *Planner) PlanWeeks(weeks []*Week) error {
+	history := p.History
+	defer func() {
+		p.History = history
+		p.uses = nil
+	}()
+	p.History = append([]*Day{}, history...)
+	p.uses = map[string]int{}
+
+	for _, week := range weeks {
+		if err := p.Fill(week.Days); err != nil {
+			return fmt.Errorf("week %d: %w", week.Number, err)
+		}
+		for _, meal := range mealsOf(week.Days) {
+			p.uses[meal.meal.Recipe.Name]++
+		}
+		p.History = append(p.History, week.Days...)
+	}
+	return nil
+}
+
+// Fill plans a recipe for every meal slot of days that does not have one
+// yet. Meals that already have a recipe are kept and taken into account by
+// the constraints. days is left untouched if no plan satisfies the hard
+// constraints, apart from days without an id getting one so that leftovers
+// can refer to them.
+func (p *Planner) Fill(days []*Day) error {
+	if len(p.Recipes) == 0 {
+		return ErrEmptyRecipePool
+	}
+
+	s := &search{
+		planner:    p,
+		days:       days,
+		pools:      map[MealSlot][]*Recipe{},
+		leftovers:  map[int]int{},
+		eaten:      map[string][]time.Time{},
+		baseline:   map[Constraint]int{},
+		rejections: map[string]int{},
+	}
+	added := []slotMeal{}
+	for _, day := range days {
+		if day.ID == uuid.Nil {
+			day.ID = uuid.New()
+		}
+		for _, slot := range p.slots() {
+			if _, ok := day.Meals[slot]; !ok {
+				day.SetRecipe(slot, nil)
+				added = append(added, slotMeal{day: day, slot: slot})
+			}
+		}
+	}
+	removeAdded := func() {
+		for _, meal := range added {
+			delete(meal.day.Meals, meal.slot)
+		}
+	}
+
+	for _, meal := range mealsOf(days) {
+		if meal.meal.Recipe != nil {
+			continue
+		}
+		if _, ok := s.pools[meal.slot]; !ok {
+			s.pools[meal.slot] = p.pool(meal.slot)
+			if len(s.pools[meal.slot]) == 0 {
+				removeAdded()
+				return fmt.Errorf("%w for %s", ErrEmptyRecipePool, meal.slot)
+			}
+		}
+		s.open = append(s.open, meal)
+	}
+	for _, meal := range mealsOf(p.History) {
+		if meal.meal.Recipe != nil {
+			s.eaten[meal.meal.Recipe.Name] = append(s.eaten[meal.meal.Recipe.Name], meal.day.Date)
+		}
+	}
+	for i, meal := range s.open {
+		for j, next := range s.open[i+1:] {
+			if next.slot == meal.slot && next.day.Date.Sub(meal.day.Date) == 24*time.Hour {
+				s.leftovers[i] = i + 1 + j
+				break
+			}
+		}
+	}
+	// Fixed meals may already break a hard constraint, only placements
+	// that make it worse are rejected.
+	for _, c := range p.Constraints {
+		if c.Hard() {
+			s.baseline[c] = c.Violations(days, p.History)
+		}
+	}
+
+	attempts := 1
+	if p.hasSoftConstraints() && p.Attempts > 1 {
+		attempts = p.Attempts
+	}
+
+	var best []Meal
+	bestPenalty := math.Inf(1)
+	// An attempt that fails without running out of steps has tried every
+	// placement, which proves that there is no plan.
+	infeasible := false
+	for i := 0; i < attempts && bestPenalty > 0; i++ {
+		s.steps = 0
+		s.exhausted = false
+		if !s.place(0) {
+			infeasible = infeasible || !s.exhausted
+			s.clear()
+			continue
+		}
+		penalty := p.penalty(days)
+		if penalty < bestPenalty {
+			bestPenalty = penalty
+			best = s.placements()
+		}
+		s.clear()
+	}
+
+	if best == nil {
+		removeAdded()
+		if !infeasible {
+			return ErrSearchExhausted
+		}
+		return &InfeasibleError{Constraint: s.mostRejecting()}
+	}
+	for i, meal := range s.open {
+		*meal.meal = best[i]
+	}
+	return nil
+}
+
+func (p *Planner) slots() []MealSlot {
+	if len(p.Slots) == 0 {
+		return []MealSlot{MealDinner}
+	}
+	return p.Slots
+}
+
+// pool returns the recipes that can be planned for slot.
+func (p *Planner) pool(slot MealSlot) []*Recipe {
+	pool := []*Recipe{}
+	for _, recipe := range p.Recipes {
+		if recipe.Suits(slot) {
+			pool = append(pool, recipe)
+		}
+	}
+	return pool
+}
+
+// weight is the probability weight of recipe, lowered by how often it has
+// been used earlier in the horizon.
+func (p *Planner) weight(recipe *Recipe) float64 {
+	return recipe.ProbabilityWeight / float64(1+p.uses[recipe.Name])
+}
+
+// hasLeftovers reports whether recipe gives enough portions to be eaten
+// again as leftovers the day after.
+func (p *Planner) hasLeftovers(recipe *Recipe) bool {
+	return p.HouseholdPortions > 0 &&
+		recipe.LeftOverCompliance &&
+		recipe.Portions >= 2*p.HouseholdPortions
+}
+
+func (p *Planner) hasSoftConstraints() bool {
+	for _, c := range p.Constraints {
+		if !c.Hard() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Planner) penalty(days []*Day) float64 {
+	penalty := 0.0
+	for _, c := range p.Constraints {
+		if !c.Hard() {
+			penalty += c.Weight() * float64(c.Violations(days, p.History))
+		}
+	}
+	return penalty
+}
+
+// search is a depth first search over the open meals of a plan.
+type search struct {
+	planner *Planner
+	days    []*Day
+	open    []slotMeal
+	pools   map[MealSlot][]*Recipe
+	// leftovers maps an open meal to the open meal in the same slot the
+	// day after, which can take its leftovers.
+	leftovers map[int]int
+	// eaten holds the dates each recipe was eaten in the history.
+	eaten      map[string][]time.Time
+	baseline   map[Constraint]int
+	rejections map[string]int
+	steps      int
+	// exhausted is set when the search stops at maxSearchSteps.
+	exhausted bool
+}
+
+func (s *search) place(i int) bool {
+	if i == len(s.open) {
+		return true
+	}
+	open := s.open[i]
+	meal := open.meal
+	if meal.Recipe != nil {
+		// Already planned as leftovers of the day before.
+		return s.place(i + 1)
+	}
+	weight := func(recipe *Recipe) float64 {
+		return s.planner.weight(recipe) *
+			s.planner.Decay.Multiplier(s.daysSince(recipe, open)) *
+			expiryMultiplier(s.planner.Pantry, recipe, open.day.Date) *
+			goalMultiplier(s.planner.Goals, recipe, open.slot)
+	}
+	for _, recipe := range weightedOrder(s.planner.Rand, s.pools[open.slot], weight) {
+		if s.steps >= maxSearchSteps {
+			s.exhausted = true
+			break
+		}
+		s.steps++
+		meal.Recipe = recipe
+		if j, ok := s.leftovers[i]; ok && s.planner.hasLeftovers(recipe) {
+			next := s.open[j].meal
+			next.Recipe = recipe
+			next.LeftoverOf = &open.day.ID
+			if s.allowed() && s.place(i+1) {
+				return true
+			}
+			next.Recipe = nil
+			next.LeftoverOf = nil
+			continue
+		}
+		if s.allowed() && s.place(i+1) {
+			return true
+		}
+	}
+	meal.Recipe = nil
+	return false
+}
+
+func (s *search) allowed() bool {
+	allowed := true
+	for _, c := range s.planner.Constraints {
+		if !c.Hard() {
+			continue
+		}
+		if c.Violations(s.days, s.planner.History) > s.baseline[c] {
+			s.rejections[c.Name()]++
+			allowed = false
+		}
+	}
+	return allowed
+}
+
+// daysSince returns the number of days between the open meal and the
+// closest other meal recipe is planned for, or -1 if it is not planned
+// anywhere.
+func (s *search) daysSince(recipe *Recipe, open slotMeal) int {
+	closest := -1
+	check := func(other slotMeal) {
+		if other.meal == open.meal || other.meal.Recipe == nil || other.meal.Recipe.Name != recipe.Name {
+			return
+		}
+		distance := GetAbsoluteTimeDifferenceInDays(open.day.Date, other.day.Date)
+		if closest < 0 || distance < closest {
+			closest = distance
+		}
+	}
+	for _, date := range s.eaten[recipe.Name] {
+		distance := GetAbsoluteTimeDifferenceInDays(open.day.Date, date)
+		if closest < 0 || distance < closest {
+			closest = distance
+		}
+	}
+	for _, other := range mealsOf(s.days) {
+		check(other)
+	}
+	return closest
+}
+
+func (s *search) placements() []Meal {
+	placements := make([]Meal, len(s.open))
+	for i, open := range s.open {
+		placements[i] = *open.meal
+	}
+	return placements
+}
+
+func (s *search) clear() {
+	for _, open := range s.open {
+		open.meal.Recipe = nil
+		open.meal.LeftoverOf = nil
+	}
+}
+
+func (s *search) mostRejecting() string {
+	name, most := "", 0
+	for n, count := range s.rejections {
+		if count > most || (count == most && n < name) {
+			name, most = n, count
+		}
+	}
+	return name
+}
+
+// weightedOrder returns recipes in a random order where recipes with a
+// higher weight tend to come first. Recipes without weight are placed last
+// and only used when nothing else fits.
+func weightedOrder(rnd *rand.Rand, recipes []*Recipe, weight func(*Recipe) float64) []*Recipe {
+	type keyed struct {
+		recipe *Recipe
+		key    float64
+	}
+	keys := make([]keyed, len(recipes))
+	for i, recipe := range recipes {
+		key := -1 - rnd.Float64()
+		if w := weight(recipe); w > 0 {
+			key = math.Pow(rnd.Float64(), 1/w)
+		}
+		keys[i] = keyed{recipe: recipe, key: key}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].key > keys[j].key
+	})
+	ordered := make([]*Recipe, len(keys))
+	for i, k := range keys {
+		ordered[i] = k.recipe
+	}
+	return ordered
+}
@@ -0,0 +1,365 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlanWeekNoRepeats(t *testing.T) {
+	recipes := createSomeRecipes(7)
+	planner := NewPlanner(NewRand(1), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, day := range week.Days {
+		if day.Recipe(MealDinner) == nil {
+			t.Fatalf("Expected dinner on %s", day.Date)
+		}
+		for _, other := range week.Days[i+1:] {
+			if day.Recipe(MealDinner) == other.Recipe(MealDinner) && GetAbsoluteTimeDifferenceInDays(day.Date, other.Date) <= DefaultNoRepeatDays {
+				t.Errorf("Expected %s not to be repeated within %d days", day.Recipe(MealDinner).Name, DefaultNoRepeatDays)
+			}
+		}
+	}
+}
+
+func TestPlanWeekConsidersHistory(t *testing.T) {
+	recipes := createSomeRecipes(8)
+	history := GenerateDays(2023, 35)
+	for i, day := range history {
+		day.SetRecipe(MealDinner, recipes[i])
+	}
+	planner := NewPlanner(NewRand(1), recipes, history, NoRepeatWithin(DefaultNoRepeatDays))
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday to sunday of week 35 are within five days of monday.
+	for _, day := range history[2:] {
+		if week.Days[0].Recipe(MealDinner) == day.Recipe(MealDinner) {
+			t.Errorf("Expected monday not to repeat the dinner of %s", day.Date.Weekday())
+		}
+	}
+}
+
+func TestPlanWeekInfeasible(t *testing.T) {
+	recipes := createSomeRecipes(3)
+	planner := NewPlanner(NewRand(1), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+
+	_, err := planner.PlanWeek(2023, 36)
+	var infeasible *InfeasibleError
+	if !errors.As(err, &infeasible) {
+		t.Fatalf("Expected infeasible error, got %v", err)
+	}
+	if infeasible.Constraint != "no repeat within 5 days" {
+		t.Errorf("Expected no repeat constraint to be reported, got %q", infeasible.Constraint)
+	}
+}
+
+func TestPlanWeekSearchExhausted(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	recipes[0].Name = "Fish tacos"
+	// Only one fish recipe can't be planned three times without repeating
+	// it within five days, but that is only found out on the last days,
+	// after more placements than the search tries.
+	planner := NewPlanner(NewRand(1), recipes, nil,
+		NoRepeatWithin(DefaultNoRepeatDays),
+		AtLeast(3, "fish", NameContains("fish")),
+	)
+
+	_, err := planner.PlanWeek(2023, 36)
+	if !errors.Is(err, ErrSearchExhausted) {
+		t.Fatalf("Expected the search to give up, got %v", err)
+	}
+	var infeasible *InfeasibleError
+	if errors.As(err, &infeasible) {
+		t.Errorf("Expected giving up not to be reported as infeasible, got %v", err)
+	}
+}
+
+func TestPlanWeekCountConstraints(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	recipes[0].Name = "Fish tacos"
+	recipes[1].Name = "Pasta carbonara"
+	recipes[2].Name = "Pasta pesto"
+	recipes[3].Name = "Pasta bolognese"
+	planner := NewPlanner(NewRand(1), recipes, nil,
+		NoRepeatWithin(DefaultNoRepeatDays),
+		AtLeast(1, "fish", NameContains("fish")),
+		AtMost(1, "pasta", NameContains("pasta")),
+	)
+
+	for i := 0; i < 50; i++ {
+		week, err := planner.PlanWeek(2023, 36)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fish, pasta := 0, 0
+		for _, day := range week.Days {
+			if NameContains("fish")(day.Recipe(MealDinner)) {
+				fish++
+			}
+			if NameContains("pasta")(day.Recipe(MealDinner)) {
+				pasta++
+			}
+		}
+		if fish < 1 {
+			t.Errorf("Expected at least one fish dinner, got %d", fish)
+		}
+		if pasta > 1 {
+			t.Errorf("Expected at most one pasta dinner, got %d", pasta)
+		}
+	}
+}
+
+func TestPlanWeekOnWeekdays(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	for _, recipe := range recipes[:5] {
+		recipe.Name = "Quick " + recipe.Name
+	}
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	planner := NewPlanner(NewRand(1), recipes, nil,
+		NoRepeatWithin(DefaultNoRepeatDays),
+		OnWeekdays(weekdays, "quick", NameContains("quick")),
+	)
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range week.Days[:5] {
+		if !NameContains("quick")(day.Recipe(MealDinner)) {
+			t.Errorf("Expected a quick dinner on %s, got %s", day.Date.Weekday(), day.Recipe(MealDinner).Name)
+		}
+	}
+}
+
+func TestFillKeepsPlannedDays(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	days := GenerateDays(2023, 36)
+	days[3].SetRecipe(MealDinner, recipes[0])
+	planner := NewPlanner(NewRand(1), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+
+	if err := planner.Fill(days); err != nil {
+		t.Fatal(err)
+	}
+	if days[3].Recipe(MealDinner) != recipes[0] {
+		t.Errorf("Expected thursday to keep %s, got %s", recipes[0].Name, days[3].Recipe(MealDinner).Name)
+	}
+	for i, day := range days {
+		if i != 3 && day.Recipe(MealDinner) == recipes[0] {
+			t.Errorf("Expected %s not to be repeated on %s", recipes[0].Name, day.Date.Weekday())
+		}
+	}
+}
+
+func TestPlanWeekPrefersSoftConstraints(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	// Random weights could make fish too unlikely for any attempt to try it.
+	for _, recipe := range recipes {
+		recipe.ProbabilityWeight = 1
+	}
+	recipes[0].Name = "Fish soup"
+	planner := NewPlanner(NewRand(1), recipes, nil,
+		NoRepeatWithin(DefaultNoRepeatDays),
+		Soft(AtLeast(1, "fish", NameContains("fish")), 1),
+	)
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, day := range week.Days {
+		if day.Recipe(MealDinner) == recipes[0] {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the soft constraint to get fish planned")
+	}
+}
+
+func TestPlanWeekEmptyPool(t *testing.T) {
+	planner := NewPlanner(NewRand(1), nil, nil)
+	if _, err := planner.PlanWeek(2023, 36); !errors.Is(err, ErrEmptyRecipePool) {
+		t.Errorf("Expected empty pool error, got %v", err)
+	}
+}
+
+func TestPlanWeekSameSeedSamePlan(t *testing.T) {
+	recipes := createSomeRecipes(20)
+	plan := func(seed int64) []string {
+		planner := NewPlanner(NewRand(seed), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+		week, err := planner.PlanWeek(2023, 36)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, len(week.Days))
+		for i, day := range week.Days {
+			names[i] = day.Recipe(MealDinner).Name
+		}
+		return names
+	}
+
+	first := plan(42)
+	for i := 0; i < 10; i++ {
+		again := plan(42)
+		for j := range first {
+			if first[j] != again[j] {
+				t.Fatalf("Expected the same plan for the same seed, got %v and %v", first, again)
+			}
+		}
+	}
+}
+
+func TestPlanWeeksAcrossWeekBoundaries(t *testing.T) {
+	recipes := createSomeRecipes(12)
+	planner := NewPlanner(NewRand(3), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+	weeks := GenerateHorizon(2023, 51, 3)
+
+	if err := planner.PlanWeeks(weeks); err != nil {
+		t.Fatal(err)
+	}
+	days := []*Day{}
+	for _, week := range weeks {
+		days = append(days, week.Days...)
+	}
+	for i, day := range days {
+		for _, other := range days[i+1:] {
+			if day.Recipe(MealDinner) == other.Recipe(MealDinner) && GetAbsoluteTimeDifferenceInDays(day.Date, other.Date) <= DefaultNoRepeatDays {
+				t.Errorf("Expected %s not to be repeated on %s and %s", day.Recipe(MealDinner).Name, day.Date, other.Date)
+			}
+		}
+	}
+	if planner.History != nil {
+		t.Errorf("Expected planner history to be restored")
+	}
+}
+
+func TestPlannerDecayPrefersLongAgoEatenRecipes(t *testing.T) {
+	recipes := createSomeRecipes(2)
+	for _, recipe := range recipes {
+		recipe.ProbabilityWeight = 1
+	}
+	// Recipe 0 was eaten the monday before, recipe 1 not at all.
+	history := []*Day{
+		{Date: GenerateDays(2023, 35)[0].Date, Meals: map[MealSlot]*Meal{MealDinner: {Recipe: recipes[0]}}},
+	}
+	planner := NewPlanner(NewRand(9), recipes, history)
+	planner.Decay = RecencyDecay{Curve: DecayLinear, HorizonDays: 100}
+
+	picked := 0
+	for i := 0; i < 200; i++ {
+		days := GenerateDays(2023, 36)[:1]
+		if err := planner.Fill(days); err != nil {
+			t.Fatal(err)
+		}
+		if days[0].Recipe(MealDinner) == recipes[0] {
+			picked++
+		}
+	}
+	if picked > 50 {
+		t.Errorf("Expected the recently eaten recipe to be picked rarely, got %d of 200", picked)
+	}
+}
+
+func TestPlanWeekSchedulesLeftovers(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	for i, recipe := range recipes {
+		recipe.Portions = 4
+		recipe.LeftOverCompliance = i%2 == 0
+	}
+	planner := NewPlanner(NewRand(4), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+	planner.HouseholdPortions = 2
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leftovers := 0
+	for i, day := range week.Days {
+		dinner := day.Meals[MealDinner]
+		if i > 0 {
+			previous := week.Days[i-1].Meals[MealDinner]
+			if !previous.IsLeftover() && previous.Recipe.LeftOverCompliance && !dinner.IsLeftover() {
+				t.Errorf("Expected leftovers on %s after %s", day.Date.Weekday(), previous.Recipe.Name)
+			}
+		}
+		if !dinner.IsLeftover() {
+			continue
+		}
+		leftovers++
+		previous := week.Days[i-1]
+		if *dinner.LeftoverOf != previous.ID {
+			t.Errorf("Expected %s to be leftovers of the day before", day.Date.Weekday())
+		}
+		if dinner.Recipe != previous.Recipe(MealDinner) || !dinner.Recipe.LeftOverCompliance {
+			t.Errorf("Expected leftovers of a compliant %s, got %s", previous.Recipe(MealDinner).Name, dinner.Recipe.Name)
+		}
+	}
+	if leftovers == 0 {
+		t.Errorf("Expected at least one leftovers day")
+	}
+}
+
+func TestPlanWeekNoLeftoversWithoutEnoughPortions(t *testing.T) {
+	recipes := createSomeRecipes(10)
+	for _, recipe := range recipes {
+		recipe.Portions = 3
+		recipe.LeftOverCompliance = true
+	}
+	planner := NewPlanner(NewRand(4), recipes, nil, NoRepeatWithin(DefaultNoRepeatDays))
+	planner.HouseholdPortions = 2
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range week.Days {
+		if day.Meals[MealDinner].IsLeftover() {
+			t.Errorf("Expected no leftovers from 3 portions for 2, got some on %s", day.Date.Weekday())
+		}
+	}
+}
+
+func TestPlanWeekFillsEnabledSlots(t *testing.T) {
+	recipes := createSomeRecipes(20)
+	for _, recipe := range recipes[:10] {
+		recipe.Slots = []MealSlot{MealBreakfast}
+	}
+	planner := NewPlanner(NewRand(5), recipes, nil, NoRepeatWithin(2))
+	planner.Slots = []MealSlot{MealBreakfast, MealDinner}
+
+	week, err := planner.PlanWeek(2023, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range week.Days {
+		if len(day.Slots()) != 2 {
+			t.Errorf("Expected breakfast and dinner on %s, got %v", day.Date.Weekday(), day.Slots())
+		}
+		for _, slot := range planner.Slots {
+			if recipe := day.Recipe(slot); recipe == nil || !recipe.Suits(slot) {
+				t.Errorf("Expected a %s recipe on %s", slot, day.Date.Weekday())
+			}
+		}
+	}
+}
+
+func TestPlanWeekEmptySlotPool(t *testing.T) {
+	planner := NewPlanner(NewRand(5), createSomeRecipes(10), nil)
+	planner.Slots = []MealSlot{MealLunch}
+
+	days := GenerateDays(2023, 36)
+	if err := planner.Fill(days); !errors.Is(err, ErrEmptyRecipePool) {
+		t.Errorf("Expected empty pool error, got %v", err)
+	}
+	if len(days[0].Meals) != 0 {
+		t.Errorf("Expected days to be left untouched, got %v", days[0].Slots())
+	}
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownUnit       = errors.New("unknown unit")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+// Dimension is what a unit measures. Units can only be converted to units
+// of the same dimension, or between mass and volume using the density of
+// an ingredient.
+type Dimension string
+
+const (
+	DimensionMass   Dimension = "mass"
+	DimensionVolume Dimension = "volume"
+	DimensionCount  Dimension = "count"
+)
+
+// Unit is a unit in the registry. Factor is the size of the unit in the
+// base unit of its dimension, gram, millilitre or piece.
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+	Factor    float64
+}
+
+var (
+	unitGram       = Unit{"g", DimensionMass, 1}
+	unitMillilitre = Unit{"ml", DimensionVolume, 1}
+	unitPiece      = Unit{"st", DimensionCount, 1}
+)
+
+// unitRegistry holds every known unit by symbol and alias. Symbols are
+// lower case.
+var unitRegistry = map[string]Unit{
+	"mg": {"mg", DimensionMass, 0.001},
+	"g":  unitGram,
+	"hg": {"hg", DimensionMass, 100},
+	"kg": {"kg", DimensionMass, 1000},
+
+	"ml": unitMillilitre,
+	"cl": {"cl", DimensionVolume, 10},
+	"dl": {"dl", DimensionVolume, 100},
+	"l":  {"l", DimensionVolume, 1000},
+	// Swedish kitchen measures.
+	"krm": {"krm", DimensionVolume, 1},
+	"tsk": {"tsk", DimensionVolume, 5},
+	"msk": {"msk", DimensionVolume, 15},
+
+	"st":  unitPiece,
+	"pc":  unitPiece,
+	"pcs": unitPiece,
+	"":    unitPiece,
+}
+
+// LookupUnit returns the unit with the symbol. An empty symbol is pieces.
+func LookupUnit(symbol string) (Unit, error) {
+	unit, ok := unitRegistry[strings.ToLower(strings.TrimSpace(symbol))]
+	if !ok {
+		return Unit{}, fmt.Errorf("%w %q", ErrUnknownUnit, symbol)
+	}
+	return unit, nil
+}
+
+// densities holds grams per millilitre of ingredients that are measured by
+// both mass and volume, by lower case name.
+var densities = map[string]float64{
+	"water":         1,
+	"vatten":        1,
+	"milk":          1.03,
+	"mjölk":         1.03,
+	"cream":         1,
+	"grädde":        1,
+	"flour":         0.6,
+	"vetemjöl":      0.6,
+	"sugar":         0.85,
+	"socker":        0.85,
+	"salt":          1.2,
+	"butter":        0.95,
+	"smör":          0.95,
+	"oil":           0.92,
+	"olja":          0.92,
+	"olive oil":     0.92,
+	"olivolja":      0.92,
+	"rice":          0.85,
+	"ris":           0.85,
+	"oats":          0.35,
+	"havregryn":     0.35,
+	"honey":         1.4,
+	"honung":        1.4,
+	"crème fraiche": 1,
+}
+
+// Density returns the grams per millilitre of the ingredient, false when it
+// is not known.
+func Density(ingredient string) (float64, bool) {
+	density, ok := densities[strings.ToLower(strings.TrimSpace(ingredient))]
+	return density, ok
+}
+
+// Quantity is an amount of a unit in the registry.
+type Quantity struct {
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit,omitempty"`
+}
+
+// NewQuantity returns a quantity of a known unit.
+func NewQuantity(amount float64, symbol string) (Quantity, error) {
+	unit, err := LookupUnit(symbol)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Amount: amount, Unit: unit.Symbol}, nil
+}
+
+func (q Quantity) unit() Unit {
+	unit, err := LookupUnit(q.Unit)
+	if err != nil {
+		// Quantities are only made from known units, keep unknown ones
+		// apart from everything else.
+		return Unit{Symbol: q.Unit, Dimension: Dimension(q.Unit), Factor: 1}
+	}
+	return unit
+}
+
+func (q Quantity) Dimension() Dimension {
+	return q.unit().Dimension
+}
+
+// Base returns the quantity in the base unit of its dimension.
+func (q Quantity) Base() Quantity {
+	unit := q.unit()
+	base := map[Dimension]Unit{
+		DimensionMass:   unitGram,
+		DimensionVolume: unitMillilitre,
+		DimensionCount:  unitPiece,
+	}[unit.Dimension]
+	if base.Symbol == "" {
+		return q
+	}
+	return Quantity{Amount: q.Amount * unit.Factor, Unit: base.Symbol}
+}
+
+// Convert returns the quantity in the unit with the symbol.
+func (q Quantity) Convert(symbol string) (Quantity, error) {
+	to, err := LookupUnit(symbol)
+	if err != nil {
+		return Quantity{}, err
+	}
+	from := q.unit()
+	if from.Dimension != to.Dimension {
+		return Quantity{}, fmt.Errorf("%w: %s and %s", ErrIncompatibleUnits, from.Symbol, to.Symbol)
+	}
+	return Quantity{Amount: q.Amount * from.Factor / to.Factor, Unit: to.Symbol}, nil
+}
+
+// ConvertFor is Convert that also converts between mass and volume using the
+// density of the ingredient.
+func (q Quantity) ConvertFor(ingredient, symbol string) (Quantity, error) {
+	to, err := LookupUnit(symbol)
+	if err != nil {
+		return Quantity{}, err
+	}
+	from := q.unit()
+	density, known := Density(ingredient)
+	switch {
+	case from.Dimension == to.Dimension:
+		return q.Convert(symbol)
+	case !known:
+		return Quantity{}, fmt.Errorf("%w: %s and %s without a density for %s", ErrIncompatibleUnits, from.Symbol, to.Symbol, ingredient)
+	case from.Dimension == DimensionVolume && to.Dimension == DimensionMass:
+		return Quantity{Amount: q.Amount * from.Factor * density / to.Factor, Unit: to.Symbol}, nil
+	case from.Dimension == DimensionMass && to.Dimension == DimensionVolume:
+		return Quantity{Amount: q.Amount * from.Factor / density / to.Factor, Unit: to.Symbol}, nil
+	}
+	return Quantity{}, fmt.Errorf("%w: %s and %s", ErrIncompatibleUnits, from.Symbol, to.Symbol)
+}
+
+// Add returns the sum of the quantities in the unit of q.
+func (q Quantity) Add(other Quantity) (Quantity, error) {
+	converted, err := other.Convert(q.Unit)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Amount: q.Amount + converted.Amount, Unit: q.Unit}, nil
+}
+
+func (q Quantity) Scale(factor float64) Quantity {
+	return Quantity{Amount: q.Amount * factor, Unit: q.Unit}
+}
+
+// spoonMeasures are the volume units that are measured with a spoon, in
+// halves and quarters.
+var spoonMeasures = map[string]bool{"krm": true, "tsk": true, "msk": true}
+
+// Round rounds the amount to what is easy to measure. Pieces are rounded
+// to halves, spoon measures to halves or quarters below one. Other amounts
+// are rounded to two significant digits, so that they are off by at most
+// 5% whatever the unit: 0.6 kg stays 0.6 kg and 333 g becomes 330 g. A
+// positive amount is never rounded down to nothing.
+func (q Quantity) Round() Quantity {
+	if q.Amount <= 0 {
+		return q
+	}
+	var step float64
+	switch {
+	case q.Dimension() == DimensionCount:
+		step = 0.5
+	case spoonMeasures[q.unit().Symbol] && q.Amount < 1:
+		step = 0.25
+	case spoonMeasures[q.unit().Symbol]:
+		step = 0.5
+	default:
+		step = math.Pow(10, math.Floor(math.Log10(q.Amount))-1)
+	}
+	amount := math.Round(q.Amount/step) * step
+	if amount == 0 {
+		amount = step
+	}
+	// Steps below one aren't exact in binary, e.g. 0.6 would be
+	// 0.6000000000000001.
+	amount, _ = strconv.ParseFloat(strconv.FormatFloat(amount, 'g', 12, 64), 64)
+	return Quantity{Amount: amount, Unit: q.Unit}
+}
+
+// Readable returns the quantity in the unit it is easiest to read in,
+// kilograms from a kilogram, litres from a litre and decilitres from a
+// decilitre. Pieces and unknown units are kept.
+func (q Quantity) Readable() Quantity {
+	base := q.Base()
+	var symbol string
+	switch {
+	case base.Unit == unitGram.Symbol && base.Amount >= 1000:
+		symbol = "kg"
+	case base.Unit == unitGram.Symbol:
+		symbol = "g"
+	case base.Unit == unitMillilitre.Symbol && base.Amount >= 1000:
+		symbol = "l"
+	case base.Unit == unitMillilitre.Symbol && base.Amount >= 100:
+		symbol = "dl"
+	case base.Unit == unitMillilitre.Symbol:
+		symbol = "ml"
+	default:
+		return q
+	}
+	readable, _ := base.Convert(symbol)
+	return readable
+}
+
+func (q Quantity) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%g %s", q.Amount, q.Unit))
+}
+
+// Quantity returns the amount of the item, an error when its unit is not
+// known.
+func (i *Item) Quantity() (Quantity, error) {
+	return NewQuantity(i.Amount, i.Unit)
+}
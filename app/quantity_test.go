@@ -0,0 +1,128 @@
+package app
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestQuantityConvert(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		from, to string
+		expected float64
+	}{
+		{1, "kg", "g", 1000},
+		{500, "g", "hg", 5},
+		{2, "dl", "ml", 200},
+		{1, "l", "dl", 10},
+		{1, "msk", "tsk", 3},
+		{1, "tsk", "krm", 5},
+		{3, "st", "pcs", 3},
+	}
+	for _, test := range tests {
+		quantity, err := NewQuantity(test.amount, test.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := quantity.Convert(test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(converted.Amount-test.expected) > 1e-9 {
+			t.Errorf("Expected %v %s to be %v %s, got %v", test.amount, test.from, test.expected, test.to, converted.Amount)
+		}
+	}
+}
+
+func TestQuantityIncompatibleUnits(t *testing.T) {
+	quantity, _ := NewQuantity(1, "dl")
+	if _, err := quantity.Convert("g"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Errorf("Expected incompatible units, got %v", err)
+	}
+	if _, err := quantity.ConvertFor("gravel", "g"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Errorf("Expected incompatible units without a density, got %v", err)
+	}
+	if _, err := NewQuantity(1, "bunch"); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("Expected unknown unit, got %v", err)
+	}
+}
+
+func TestQuantityConvertForDensity(t *testing.T) {
+	quantity, _ := NewQuantity(1, "dl")
+	grams, err := quantity.ConvertFor("Vetemjöl", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(grams.Amount-60) > 1e-9 {
+		t.Errorf("Expected 1 dl flour to weigh 60 g, got %v", grams.Amount)
+	}
+	quantity, _ = NewQuantity(120, "g")
+	volume, err := quantity.ConvertFor("flour", "dl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(volume.Amount-2) > 1e-9 {
+		t.Errorf("Expected 120 g flour to be 2 dl, got %v", volume.Amount)
+	}
+}
+
+func TestQuantityAdd(t *testing.T) {
+	a, _ := NewQuantity(1, "kg")
+	b, _ := NewQuantity(500, "g")
+	sum, err := a.Add(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Amount != 1.5 || sum.Unit != "kg" {
+		t.Errorf("Expected 1.5 kg, got %s", sum)
+	}
+}
+
+func TestQuantityRound(t *testing.T) {
+	tests := []struct {
+		quantity Quantity
+		expected float64
+	}{
+		{Quantity{1.0 / 3, "st"}, 0.5},
+		{Quantity{0.1, "st"}, 0.5},
+		{Quantity{2.4, "st"}, 2.5},
+		{Quantity{0.33, "tsk"}, 0.25},
+		{Quantity{1.33, "msk"}, 1.5},
+		{Quantity{0.333, "dl"}, 0.33},
+		{Quantity{33.3, "g"}, 33},
+		{Quantity{333.3, "g"}, 330},
+		{Quantity{0.6, "kg"}, 0.6},
+		{Quantity{0.612, "kg"}, 0.61},
+		{Quantity{1.3, "l"}, 1.3},
+		{Quantity{1.26, "l"}, 1.3},
+		{Quantity{2.75, "kg"}, 2.8},
+		{Quantity{9.94, "kg"}, 9.9},
+		{Quantity{0.004, "kg"}, 0.004},
+	}
+	for _, test := range tests {
+		if got := test.quantity.Round().Amount; got != test.expected {
+			t.Errorf("Expected %s to round to %v, got %v", test.quantity, test.expected, got)
+		}
+	}
+}
+
+func TestQuantityReadable(t *testing.T) {
+	tests := []struct {
+		quantity Quantity
+		expected string
+	}{
+		{Quantity{1500, "g"}, "1.5 kg"},
+		{Quantity{5, "hg"}, "500 g"},
+		{Quantity{30, "cl"}, "3 dl"},
+		{Quantity{6, "dl"}, "6 dl"},
+		{Quantity{12, "dl"}, "1.2 l"},
+		{Quantity{2, "msk"}, "30 ml"},
+		{Quantity{4, "st"}, "4 st"},
+	}
+	for _, test := range tests {
+		if got := test.quantity.Readable().String(); got != test.expected {
+			t.Errorf("Expected %s to read %s, got %s", test.quantity, test.expected, got)
+		}
+	}
+}
@@ -4,7 +4,19 @@ import (
 	"math/rand"
 )
 
-func PickRandom[T any](alternatives []*T, weightSelector func(*T) float64) *T {
+// NewSeed returns a seed for plans that were not requested with one, so
+// that every generated plan can be reproduced later.
+func NewSeed() int64 {
+	return rand.Int63()
+}
+
+// NewRand returns a random source that always yields the same sequence
+// for the same seed.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+func PickRandom[T any](rnd *rand.Rand, alternatives []*T, weightSelector func(*T) float64) *T {
 	if len(alternatives) == 0 {
 		panic("Cannot pick random from empty slice")
 	}
@@ -14,7 +26,7 @@ func PickRandom[T any](alternatives []*T, weightSelector func(*T) float64) *T {
 		totalWeight += weightSelector(alternative)
 	}
 
-	r := rand.Float64() * totalWeight
+	r := rnd.Float64() * totalWeight
 	cumulativeWeight := 0.0
 
 	for _, alternative := range alternatives {
@@ -27,11 +39,11 @@ func PickRandom[T any](alternatives []*T, weightSelector func(*T) float64) *T {
 	return alternatives[len(alternatives)-1]
 }
 
-func Shuffle[T any](items []T) []T {
+func Shuffle[T any](rnd *rand.Rand, items []T) []T {
 	// Iterate from the end to the beginning of the slice
 	for i := len(items) - 1; i > 0; i-- {
 		// Generate a random index between 0 and i (inclusive)
-		j := rand.Intn(i + 1)
+		j := rnd.Intn(i + 1)
 
 		// Swap the items at index i and j
 		items[i], items[j] = items[j], items[i]
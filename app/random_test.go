@@ -0,0 +1,30 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestShuffleSameSeedSameOrder(t *testing.T) {
+	items := func() []int {
+		return []int{1, 2, 3, 4, 5, 6, 7}
+	}
+	first := Shuffle(NewRand(7), items())
+	second := Shuffle(NewRand(7), items())
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected the same order for the same seed, got %v and %v", first, second)
+		}
+	}
+}
+
+func TestPickRandomSameSeedSamePick(t *testing.T) {
+	recipes := createSomeRecipes(50)
+	weight := func(r *Recipe) float64 { return r.ProbabilityWeight }
+	for seed := int64(0); seed < 10; seed++ {
+		first := PickRandom(NewRand(seed), recipes, weight)
+		second := PickRandom(NewRand(seed), recipes, weight)
+		if first != second {
+			t.Errorf("Expected the same pick for seed %d, got %s and %s", seed, first.Name, second.Name)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package app
+
+// UserRole decides what a user may do outside of their own household.
+type UserRole string
+
+const (
+	UserRoleUser UserRole = "user"
+	// UserRoleAdmin may manage every user and what is shared between them.
+	UserRoleAdmin UserRole = "admin"
+)
+
+var UserRoles = []UserRole{UserRoleUser, UserRoleAdmin}
+
+func (r UserRole) Valid() bool {
+	for _, role := range UserRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the user may use the admin routes and act on
+// behalf of other users.
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
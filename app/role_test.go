@@ -0,0 +1,16 @@
+package app
+
+import "testing"
+
+func TestUserRoles(t *testing.T) {
+	admin := &User{Role: UserRoleAdmin}
+	user := &User{Role: UserRoleUser}
+	if !admin.IsAdmin() || user.IsAdmin() || (&User{}).IsAdmin() {
+		t.Error("Expected only users with the admin role to be admins")
+	}
+	for _, role := range []UserRole{"", "owner", "Admin"} {
+		if role.Valid() {
+			t.Errorf("Expected %q not to be a valid role", role)
+		}
+	}
+}
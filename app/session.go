@@ -0,0 +1,58 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// SessionIdleTimeout is how long a session lasts without being used.
+	SessionIdleTimeout = 14 * 24 * time.Hour
+	// SessionMaxAge is how long a session lasts at most since the login, no
+	// matter how often it is used.
+	SessionMaxAge = 90 * 24 * time.Hour
+)
+
+// ErrSessionExpired is returned for tokens of sessions that have been idle
+// or alive for too long.
+var ErrSessionExpired = errors.New("session expired")
+
+// Session is a login on one device. Only a hash of its token is stored.
+type Session struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Label      string    `json:"label"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current is set for the session of the request listing the sessions.
+	Current bool `json:"current,omitempty"`
+	Entity
+}
+
+// NewSession returns a session of the user starting at now.
+func NewSession(userID uuid.UUID, label string, now time.Time) *Session {
+	return &Session{
+		UserID:     userID,
+		Label:      label,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(SessionMaxAge),
+		Entity:     Entity{ID: uuid.New()},
+	}
+}
+
+// Expired reports whether the session can no longer be used at now.
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt) || !now.Before(s.LastSeenAt.Add(SessionIdleTimeout))
+}
+
+// HashToken returns the hash tokens are stored and looked up by, so that a
+// leaked database does not leak working tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
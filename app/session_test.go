@@ -0,0 +1,30 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSessionExpired(t *testing.T) {
+	login := time.Date(2023, 9, 4, 12, 0, 0, 0, time.UTC)
+	session := NewSession(uuid.New(), "laptop", login)
+
+	if session.Expired(login.Add(SessionIdleTimeout - time.Minute)) {
+		t.Error("Expected a session used within the idle timeout not to expire")
+	}
+	if !session.Expired(login.Add(SessionIdleTimeout)) {
+		t.Error("Expected an idle session to expire")
+	}
+	session.LastSeenAt = login.Add(SessionMaxAge - time.Hour)
+	if !session.Expired(login.Add(SessionMaxAge)) {
+		t.Error("Expected a session in use to expire at its max age")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	if HashToken("a") == HashToken("b") || HashToken("a") != HashToken("a") || HashToken("a") == "a" {
+		t.Error("Expected tokens to hash to distinct, stable values other than the token")
+	}
+}
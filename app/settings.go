@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"math"
+)
+
+// DecayCurve names how fast a recipe becomes likely again after it was eaten.
+type DecayCurve string
+
+const (
+	// DecayNone gives every recipe its full weight no matter when it was
+	// last eaten, apart from the no repeat rule.
+	DecayNone DecayCurve = "none"
+	// DecayLinear recovers the weight evenly over the horizon.
+	DecayLinear DecayCurve = "linear"
+	// DecayExponential recovers most of the weight early in the horizon
+	// and the rest slowly.
+	DecayExponential DecayCurve = "exponential"
+)
+
+const maxDecayHorizonDays = 365
+
+// RecencyDecay lowers the selection weight of recently eaten recipes,
+// recovering to the full weight HorizonDays after the recipe was eaten.
+type RecencyDecay struct {
+	Curve       DecayCurve `json:"decay_curve,omitempty"`
+	HorizonDays int        `json:"decay_horizon_days,omitempty"`
+}
+
+// Multiplier returns the factor to scale the weight of a recipe eaten
+// daysSince days ago with, between 0 and 1. A negative daysSince means the
+// recipe has not been eaten.
+func (d RecencyDecay) Multiplier(daysSince int) float64 {
+	if daysSince < 0 || daysSince >= d.HorizonDays {
+		return 1
+	}
+	progress := float64(daysSince) / float64(d.HorizonDays)
+	switch d.Curve {
+	case DecayLinear:
+		return progress
+	case DecayExponential:
+		// Normalised so that the curve reaches 1 at the end of the horizon.
+		return (1 - math.Exp(-3*progress)) / (1 - math.Exp(-3))
+	default:
+		return 1
+	}
+}
+
+// PlannerSettings are the per user settings used when generating weeks.
+type PlannerSettings struct {
+	RecencyDecay
+	// HouseholdPortions is how many portions the household eats a day,
+	// 0 turns off leftover planning.
+	HouseholdPortions int `json:"household_portions"`
+	// MealSlots are the meals planned every day, only dinner when empty.
+	MealSlots []MealSlot `json:"meal_slots,omitempty"`
+	// WeeklyBudget is what the household wants to spend on food a week,
+	// 0 for no budget.
+	WeeklyBudget float64 `json:"weekly_budget"`
+}
+
+// DefaultPlannerSettings returns the settings of users that have not saved
+// any of their own.
+func DefaultPlannerSettings() *PlannerSettings {
+	return &PlannerSettings{
+		RecencyDecay: RecencyDecay{
+			Curve:       DecayLinear,
+			HorizonDays: 28,
+		},
+		MealSlots: []MealSlot{MealDinner},
+	}
+}
+
+// Validate returns a list of problems with the settings, empty if they
+// can be saved.
+func (s *PlannerSettings) Validate() []string {
+	errors := []string{}
+	switch s.Curve {
+	case DecayNone, DecayLinear, DecayExponential:
+	default:
+		errors = append(errors, fmt.Sprintf("decay_curve need to be one of %s, %s or %s", DecayNone, DecayLinear, DecayExponential))
+	}
+	if s.HorizonDays < 1 || s.HorizonDays > maxDecayHorizonDays {
+		errors = append(errors, fmt.Sprintf("decay_horizon_days need to be set between 1 and %d", maxDecayHorizonDays))
+	}
+	if s.HouseholdPortions < 0 {
+		errors = append(errors, "household_portions can not be negative")
+	}
+	if s.WeeklyBudget < 0 {
+		errors = append(errors, "weekly_budget can not be negative")
+	}
+	for _, slot := range s.MealSlots {
+		if !slot.Valid() {
+			errors = append(errors, fmt.Sprintf("meal_slots can only contain %v, got %q", MealSlots, slot))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRecencyDecayMultiplier(t *testing.T) {
+	tests := []struct {
+		decay     RecencyDecay
+		daysSince int
+		expected  float64
+	}{
+		{RecencyDecay{Curve: DecayLinear, HorizonDays: 20}, -1, 1},
+		{RecencyDecay{Curve: DecayLinear, HorizonDays: 20}, 0, 0},
+		{RecencyDecay{Curve: DecayLinear, HorizonDays: 20}, 5, 0.25},
+		{RecencyDecay{Curve: DecayLinear, HorizonDays: 20}, 20, 1},
+		{RecencyDecay{Curve: DecayLinear, HorizonDays: 20}, 200, 1},
+		{RecencyDecay{Curve: DecayExponential, HorizonDays: 20}, 0, 0},
+		{RecencyDecay{Curve: DecayExponential, HorizonDays: 20}, 20, 1},
+		{RecencyDecay{Curve: DecayNone, HorizonDays: 20}, 1, 1},
+		{RecencyDecay{}, 1, 1},
+	}
+	for _, test := range tests {
+		got := test.decay.Multiplier(test.daysSince)
+		if math.Abs(got-test.expected) > 1e-9 {
+			t.Errorf("Expected %s decay over %d days to give %f after %d days, got %f",
+				test.decay.Curve, test.decay.HorizonDays, test.expected, test.daysSince, got)
+		}
+	}
+}
+
+func TestExponentialDecayRecoversFasterThanLinear(t *testing.T) {
+	linear := RecencyDecay{Curve: DecayLinear, HorizonDays: 28}
+	exponential := RecencyDecay{Curve: DecayExponential, HorizonDays: 28}
+	previous := 0.0
+	for days := 1; days < 28; days++ {
+		got := exponential.Multiplier(days)
+		if got <= linear.Multiplier(days) {
+			t.Errorf("Expected exponential decay above linear after %d days", days)
+		}
+		if got <= previous {
+			t.Errorf("Expected exponential decay to increase after %d days", days)
+		}
+		previous = got
+	}
+}
+
+func TestPlannerSettingsValidate(t *testing.T) {
+	if errors := DefaultPlannerSettings().Validate(); len(errors) != 0 {
+		t.Errorf("Expected default settings to be valid, got %v", errors)
+	}
+	settings := &PlannerSettings{RecencyDecay: RecencyDecay{Curve: "sometimes", HorizonDays: 0}}
+	if errors := settings.Validate(); len(errors) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errors)
+	}
+	settings = DefaultPlannerSettings()
+	settings.MealSlots = []MealSlot{MealLunch, "supper"}
+	if errors := settings.Validate(); len(errors) != 1 {
+		t.Errorf("Expected an error for the unknown slot, got %v", errors)
+	}
+}
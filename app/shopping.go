@@ -0,0 +1,142 @@
+package app
+
+import (
+	"sort"
+	"strings"
+)
+
+// SectionOther is the store section of items that have none.
+const SectionOther = "other"
+
+// ShoppingItem is one line of a shopping list, the sum of an ingredient
+// over all meals of a week.
+type ShoppingItem struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit,omitempty"`
+	Cost   float64 `json:"cost"`
+	// InPantry is how much of the amount needed is already at home and
+	// left out of Amount, in the same unit.
+	InPantry float64 `json:"in_pantry,omitempty"`
+	// Recipes are the names of the recipes that use the item.
+	Recipes []string `json:"recipes"`
+}
+
+// ShoppingSection holds the items found in one section of the store.
+type ShoppingSection struct {
+	Name  string          `json:"name"`
+	Items []*ShoppingItem `json:"items"`
+	Cost  float64         `json:"cost"`
+}
+
+// ShoppingList is what needs to be bought to cook the meals of a week.
+type ShoppingList struct {
+	Sections []*ShoppingSection `json:"sections"`
+	// ItemCount is the number of lines on the list.
+	ItemCount int     `json:"item_count"`
+	Cost      float64 `json:"cost"`
+}
+
+// NewShoppingList sums the ingredients of every meal cooked during days,
+// scaled as by cookedMeals.
+func NewShoppingList(days []*Day, portions int) *ShoppingList {
+	list := &ShoppingList{Sections: []*ShoppingSection{}}
+	type key struct {
+		name      string
+		dimension Dimension
+	}
+	items := map[key]*ShoppingItem{}
+	amounts := map[key]Quantity{}
+	sections := map[key]string{}
+	for _, cooked := range cookedMeals(days, portions) {
+		recipe := cooked.recipe
+		list.Cost += recipe.Cost()
+		for _, item := range recipe.Items {
+			quantity, err := item.Quantity()
+			if err != nil {
+				quantity = Quantity{Amount: item.Amount, Unit: strings.ToLower(strings.TrimSpace(item.Unit))}
+			}
+			quantity = quantity.Base()
+			k := key{strings.ToLower(strings.TrimSpace(item.Name)), quantity.Dimension()}
+			line, ok := items[k]
+			if !ok {
+				line = &ShoppingItem{Name: strings.TrimSpace(item.Name)}
+				items[k] = line
+				amounts[k] = Quantity{Unit: quantity.Unit}
+			}
+			amounts[k] = Quantity{Amount: amounts[k].Amount + quantity.Amount, Unit: quantity.Unit}
+			line.Cost += float64(item.Price)
+			line.Recipes = appendUnique(line.Recipes, recipe.Name)
+			if sections[k] == "" {
+				sections[k] = item.Section
+			}
+		}
+	}
+	// An ingredient bought both by mass and by volume is bought by mass if
+	// its density is known.
+	for k, line := range items {
+		if k.dimension != DimensionVolume {
+			continue
+		}
+		massKey := key{k.name, DimensionMass}
+		mass, ok := items[massKey]
+		if !ok {
+			continue
+		}
+		grams, err := amounts[k].ConvertFor(k.name, unitGram.Symbol)
+		if err != nil {
+			continue
+		}
+		amounts[massKey] = Quantity{Amount: amounts[massKey].Amount + grams.Amount, Unit: unitGram.Symbol}
+		mass.Cost += line.Cost
+		for _, name := range line.Recipes {
+			mass.Recipes = appendUnique(mass.Recipes, name)
+		}
+		delete(items, k)
+	}
+
+	bySection := map[string]*ShoppingSection{}
+	for k, line := range items {
+		readable := amounts[k].Readable()
+		line.Amount, line.Unit = readable.Amount, readable.Unit
+		name := strings.ToLower(strings.TrimSpace(sections[k]))
+		if name == "" {
+			name = SectionOther
+		}
+		section, ok := bySection[name]
+		if !ok {
+			section = &ShoppingSection{Name: name}
+			bySection[name] = section
+			list.Sections = append(list.Sections, section)
+		}
+		section.Items = append(section.Items, line)
+		section.Cost += line.Cost
+		list.ItemCount++
+	}
+	sort.Slice(list.Sections, func(i, j int) bool {
+		a, b := list.Sections[i].Name, list.Sections[j].Name
+		if (a == SectionOther) != (b == SectionOther) {
+			return b == SectionOther
+		}
+		return a < b
+	})
+	for _, section := range list.Sections {
+		sort.Slice(section.Items, func(i, j int) bool {
+			a, b := section.Items[i], section.Items[j]
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.Unit < b.Unit
+		})
+	}
+	return list
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
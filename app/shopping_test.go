@@ -0,0 +1,113 @@
+package app
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestShoppingListMergesAndScales(t *testing.T) {
+	pasta := &Recipe{NewRecipe: NewRecipe{
+		Name:     "Pasta",
+		Portions: 4,
+		Items: []*Item{
+			{Name: "Pasta", Amount: 500, Unit: "g", Price: 20, Section: "Dry goods"},
+			{Name: "Cream", Amount: 2, Unit: "dl", Price: 16, Section: "Dairy"},
+		},
+	}}
+	soup := &Recipe{NewRecipe: NewRecipe{
+		Name:     "Soup",
+		Portions: 2,
+		Items: []*Item{
+			{Name: "cream", Amount: 300, Unit: "ml", Price: 24, Section: "Dairy"},
+			{Name: "Onion", Amount: 1, Unit: "st", Price: 3},
+		},
+	}}
+	days := GenerateDays(2023, 36)
+	for _, day := range days {
+		day.ID = uuid.New()
+	}
+	days[0].SetRecipe(MealDinner, pasta)
+	days[1].SetRecipe(MealDinner, soup)
+
+	list := NewShoppingList(days, 2)
+
+	if len(list.Sections) != 3 {
+		t.Fatalf("Expected 3 sections, got %d", len(list.Sections))
+	}
+	if list.Sections[2].Name != SectionOther {
+		t.Errorf("Expected items without section last, got %q", list.Sections[2].Name)
+	}
+	if list.ItemCount != 3 {
+		t.Errorf("Expected 3 items, got %d", list.ItemCount)
+	}
+	var cream *ShoppingItem
+	for _, section := range list.Sections {
+		if section.Name == "dairy" {
+			cream = section.Items[0]
+		}
+	}
+	if cream == nil {
+		t.Fatal("Expected cream in the dairy section")
+	}
+	// 1 dl for half the pasta and 300 ml for the soup.
+	if math.Abs(cream.Amount-4) > 1e-9 || cream.Unit != "dl" {
+		t.Errorf("Expected 4 dl cream, got %v %s", cream.Amount, cream.Unit)
+	}
+	if len(cream.Recipes) != 2 {
+		t.Errorf("Expected cream to be used by 2 recipes, got %v", cream.Recipes)
+	}
+	if list.Cost != 10+8+24+3 {
+		t.Errorf("Expected cost 45, got %v", list.Cost)
+	}
+}
+
+func TestShoppingListBuysLeftoversWithTheirMeal(t *testing.T) {
+	stew := &Recipe{NewRecipe: NewRecipe{
+		Name:               "Stew",
+		Portions:           2,
+		LeftOverCompliance: true,
+		Items:              []*Item{{Name: "Beef", Amount: 600, Unit: "g"}},
+	}}
+	days := GenerateDays(2023, 36)
+	for _, day := range days {
+		day.ID = uuid.New()
+	}
+	days[0].SetRecipe(MealDinner, stew)
+	days[1].Meals = map[MealSlot]*Meal{MealDinner: {Recipe: stew, LeftoverOf: &days[0].ID}}
+
+	list := NewShoppingList(days, 2)
+
+	beef := list.Sections[0].Items[0]
+	if math.Abs(beef.Amount-1.2) > 1e-9 || beef.Unit != "kg" {
+		t.Errorf("Expected 1.2 kg beef for two dinners of two, got %v %s", beef.Amount, beef.Unit)
+	}
+}
+
+func TestShoppingListMergesMassAndVolumeByDensity(t *testing.T) {
+	bread := &Recipe{NewRecipe: NewRecipe{
+		Name:  "Bread",
+		Items: []*Item{{Name: "Vetemjöl", Amount: 500, Unit: "g"}},
+	}}
+	pancakes := &Recipe{NewRecipe: NewRecipe{
+		Name:  "Pancakes",
+		Items: []*Item{{Name: "vetemjöl", Amount: 5, Unit: "dl"}},
+	}}
+	days := GenerateDays(2023, 36)
+	for _, day := range days {
+		day.ID = uuid.New()
+	}
+	days[0].SetRecipe(MealDinner, bread)
+	days[1].SetRecipe(MealDinner, pancakes)
+
+	list := NewShoppingList(days, 0)
+
+	if list.ItemCount != 1 {
+		t.Fatalf("Expected flour on one line, got %d lines", list.ItemCount)
+	}
+	flour := list.Sections[0].Items[0]
+	if math.Abs(flour.Amount-800) > 1e-9 || flour.Unit != "g" {
+		t.Errorf("Expected 800 g flour, got %v %s", flour.Amount, flour.Unit)
+	}
+}
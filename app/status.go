@@ -0,0 +1,31 @@
+package app
+
+// WeekStatus is where a week is in planning.
+type WeekStatus string
+
+const (
+	// WeekDraft is a generated plan not yet agreed on. A household can
+	// have several drafts, told apart by name, and a draft can span more
+	// than one week.
+	WeekDraft WeekStatus = "draft"
+	// WeekAccepted is the plan for an iso week.
+	WeekAccepted WeekStatus = "accepted"
+	// WeekArchived is a plan that has been replaced by another one for the
+	// same week.
+	WeekArchived WeekStatus = "archived"
+)
+
+var WeekStatuses = []WeekStatus{WeekDraft, WeekAccepted, WeekArchived}
+
+func (s WeekStatus) Valid() bool {
+	for _, status := range WeekStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *NewWeek) IsDraft() bool {
+	return w.Status == WeekDraft
+}
@@ -0,0 +1,19 @@
+package app
+
+import "testing"
+
+func TestWeekStatuses(t *testing.T) {
+	if !(&NewWeek{Status: WeekDraft}).IsDraft() {
+		t.Error("Expected a week with the draft status to be a draft")
+	}
+	for _, week := range []*NewWeek{{}, {Status: WeekAccepted}, {Status: WeekArchived}} {
+		if week.IsDraft() {
+			t.Errorf("Expected a %q week not to be a draft", week.Status)
+		}
+	}
+	for _, status := range []WeekStatus{"", "Draft", "planned"} {
+		if status.Valid() {
+			t.Errorf("Expected %q not to be a valid status", status)
+		}
+	}
+}
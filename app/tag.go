@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagType groups tags that describe the same property of a recipe. Tags
+// without a type are free-form.
+type TagType string
+
+const (
+	TagFree    TagType = ""
+	TagCuisine TagType = "cuisine"
+	TagProtein TagType = "protein"
+	TagDiet    TagType = "diet"
+	TagEffort  TagType = "effort"
+	TagSeason  TagType = "season"
+)
+
+// TagTypes lists the typed tags.
+var TagTypes = []TagType{TagCuisine, TagProtein, TagDiet, TagEffort, TagSeason}
+
+func (t TagType) Valid() bool {
+	if t == TagFree {
+		return true
+	}
+	for _, tagType := range TagTypes {
+		if t == tagType {
+			return true
+		}
+	}
+	return false
+}
+
+// Tag labels a recipe, written as type:value, e.g. diet:vegetarian, or just
+// value for free-form tags, e.g. oven.
+type Tag struct {
+	Type  TagType
+	Value string
+}
+
+// ParseTag reads a tag written as type:value or value. Types and values are
+// not case sensitive.
+func ParseTag(s string) (Tag, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	tag := Tag{Value: s}
+	if tagType, value, ok := strings.Cut(s, ":"); ok {
+		tag = Tag{Type: TagType(strings.TrimSpace(tagType)), Value: strings.TrimSpace(value)}
+	}
+	if !tag.Type.Valid() {
+		return Tag{}, fmt.Errorf("unknown tag type %q, need to be one of %v", tag.Type, TagTypes)
+	}
+	if tag.Value == "" {
+		return Tag{}, fmt.Errorf("tag %q has no value", s)
+	}
+	return tag, nil
+}
+
+func (t Tag) String() string {
+	if t.Type == TagFree {
+		return t.Value
+	}
+	return string(t.Type) + ":" + t.Value
+}
+
+func (t Tag) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *Tag) UnmarshalText(text []byte) error {
+	tag, err := ParseTag(string(text))
+	if err != nil {
+		return err
+	}
+	*t = tag
+	return nil
+}
+
+// HasTag reports whether the recipe is tagged with tag.
+func (r *Recipe) HasTag(tag Tag) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// TagFilter narrows a recipe pool down to the recipes that have every tag
+// in Include and none in Exclude.
+type TagFilter struct {
+	Include []Tag
+	Exclude []Tag
+}
+
+func (f TagFilter) Match(recipe *Recipe) bool {
+	for _, tag := range f.Include {
+		if !recipe.HasTag(tag) {
+			return false
+		}
+	}
+	for _, tag := range f.Exclude {
+		if recipe.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the recipes that match the filter.
+func (f TagFilter) Apply(recipes []*Recipe) []*Recipe {
+	filtered := []*Recipe{}
+	for _, recipe := range recipes {
+		if f.Match(recipe) {
+			filtered = append(filtered, recipe)
+		}
+	}
+	return filtered
+}
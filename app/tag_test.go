@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Tag
+	}{
+		{"oven", Tag{TagFree, "oven"}},
+		{" Diet:Vegetarian ", Tag{TagDiet, "vegetarian"}},
+		{"protein: fish", Tag{TagProtein, "fish"}},
+	}
+	for _, test := range tests {
+		tag, err := ParseTag(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag != test.expected {
+			t.Errorf("Expected %q to parse as %v, got %v", test.in, test.expected, tag)
+		}
+	}
+	for _, in := range []string{"colour:red", "diet:", ""} {
+		if _, err := ParseTag(in); err == nil {
+			t.Errorf("Expected %q not to parse", in)
+		}
+	}
+}
+
+func TestRecipeTagsJSON(t *testing.T) {
+	var recipe Recipe
+	err := json.Unmarshal([]byte(`{"name": "Dal", "tags": ["cuisine:indian", "diet:vegetarian", "Stew"]}`), &recipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipe.Tags) != 3 || recipe.Tags[2] != (Tag{TagFree, "stew"}) {
+		t.Fatalf("Expected three tags, got %v", recipe.Tags)
+	}
+	data, err := json.Marshal(recipe.Tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `["cuisine:indian","diet:vegetarian","stew"]` {
+		t.Errorf("Expected tags to be written as strings, got %s", data)
+	}
+	if err := json.Unmarshal([]byte(`{"tags": ["mood:happy"]}`), &recipe); err == nil {
+		t.Errorf("Expected an unknown tag type to be rejected")
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	vegetarian := Tag{TagDiet, "vegetarian"}
+	oven := Tag{TagFree, "oven"}
+	recipes := createSomeRecipes(3)
+	recipes[0].Tags = []Tag{vegetarian}
+	recipes[1].Tags = []Tag{vegetarian, oven}
+
+	filtered := TagFilter{Include: []Tag{vegetarian}, Exclude: []Tag{oven}}.Apply(recipes)
+
+	if len(filtered) != 1 || filtered[0] != recipes[0] {
+		t.Errorf("Expected only the vegetarian recipe without oven, got %v", filtered)
+	}
+	if len(TagFilter{}.Apply(recipes)) != 3 {
+		t.Errorf("Expected an empty filter to keep every recipe")
+	}
+}
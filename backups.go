@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"nrdev.se/mealshuffler/sqlite"
+)
+
+// Scheduled backups are named by the time they were made, so that they sort
+// from oldest to newest.
+const (
+	backupPrefix     = "mealshuffler-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// scheduleBackups backs up the database into dir every interval, keeping
+// the keep newest backups. Failures are logged and retried at the next
+// interval.
+func scheduleBackups(db *sql.DB, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if err := backupInto(db, dir, keep, now); err != nil {
+			log.Printf("scheduled backup failed: %v", err)
+		}
+	}
+}
+
+// backupInto backs up the database into dir and prunes the backups there.
+func backupInto(db *sql.DB, dir string, keep int, now time.Time) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, backupPrefix+now.UTC().Format(backupTimeFormat)+backupSuffix)
+	if err := sqlite.Backup(db, path); err != nil {
+		return err
+	}
+	log.Printf("backed up database to %s", path)
+	return pruneBackups(dir, keep)
+}
+
+// pruneBackups deletes all but the keep newest scheduled backups in dir.
+// Other files are left alone.
+func pruneBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	backups := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+		if _, err := time.Parse(backupTimeFormat, timestamp); err != nil {
+			continue
+		}
+		backups = append(backups, name)
+	}
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return err
+		}
+		log.Printf("deleted old backup %s", backups[0])
+		backups = backups[1:]
+	}
+	return nil
+}
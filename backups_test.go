@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"nrdev.se/mealshuffler/sqlite"
+)
+
+func backupNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"mealshuffler-20240103T000000Z.db",
+		"mealshuffler-20240101T000000Z.db",
+		"mealshuffler-20240102T000000Z.db",
+		"mealshuffler-latest.db",
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "mealshuffler-20230101T000000Z.db"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pruneBackups(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"mealshuffler-20230101T000000Z.db",
+		"mealshuffler-20240102T000000Z.db",
+		"mealshuffler-20240103T000000Z.db",
+		"mealshuffler-latest.db",
+		"notes.txt",
+	}
+	if names := backupNames(t, dir); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the oldest backup only to be deleted, got %v", names)
+	}
+
+	if err := pruneBackups(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"mealshuffler-20230101T000000Z.db", "mealshuffler-latest.db", "notes.txt"}
+	if names := backupNames(t, dir); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected every backup to be deleted, got %v", names)
+	}
+}
+
+func TestBackupInto(t *testing.T) {
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "mealshuffler.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := sqlite.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	for i := 0; i < 3; i++ {
+		if err := backupInto(db, dir, 2, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"mealshuffler-20240101T120000Z.db", "mealshuffler-20240101T130000Z.db"}
+	names := backupNames(t, dir)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected the two newest backups named in UTC, got %v", names)
+	}
+	for _, name := range names {
+		if _, err := sqlite.CheckBackup(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be a usable backup: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"nrdev.se/mealshuffler/app"
+	"nrdev.se/mealshuffler/sqlite"
+)
+
+const usage = `usage: mealshuffler [flags]                 start the server
+       mealshuffler create-admin -username u  create an admin, or make a user one
+       mealshuffler migrate up|down|status    apply all, revert the last or list migrations
+       mealshuffler backup -out file          copy the database to file, also while the server runs
+       mealshuffler restore -in file          replace the database with a backup`
+
+// dbConfig is how to open the database.
+type dbConfig struct {
+	path string
+	// migrate applies pending migrations when the database is opened.
+	migrate bool
+}
+
+// runCommand runs the command given on the command line instead of the
+// server.
+func runCommand(args []string, config dbConfig) error {
+	switch args[0] {
+	case "create-admin":
+		return createAdmin(args[1:], config)
+	case "migrate":
+		return migrate(args[1:], config)
+	case "backup":
+		return backup(args[1:], config)
+	case "restore":
+		return restore(args[1:], config)
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
+
+// openDB opens the database, first applying pending migrations if the
+// config says so.
+func openDB(config dbConfig) (*sql.DB, error) {
+	db, err := sqlite.NewDB(config.path)
+	if err != nil {
+		return nil, err
+	}
+	if !config.migrate {
+		return db, nil
+	}
+	applied, err := sqlite.MigrateUp(db)
+	for _, m := range applied {
+		log.Printf("applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrate applies or reverts migrations, or lists them with when they
+// were applied.
+func migrate(args []string, config dbConfig) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: mealshuffler migrate up|down|status")
+	}
+	db, err := sqlite.NewDB(config.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	switch args[0] {
+	case "up":
+		applied, err := sqlite.MigrateUp(db)
+		for _, m := range applied {
+			log.Printf("applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Print("no migrations to apply")
+		}
+		return err
+	case "down":
+		reverted, err := sqlite.MigrateDown(db)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			log.Print("no migrations to revert")
+			return nil
+		}
+		log.Printf("reverted migration %04d_%s", reverted.Version, reverted.Name)
+		return nil
+	case "status":
+		migrations, err := sqlite.MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			status := "pending"
+			if m.AppliedAt != nil {
+				status = "applied " + m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, status)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+}
+
+// backup writes a consistent copy of the database to a file, without
+// stopping the server.
+func backup(args []string, config dbConfig) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := flags.String("out", "", "file to write the backup to, replaced if it exists")
+	flags.Parse(args)
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+	// Opening a database that doesn't exist would create an empty one.
+	if _, err := os.Stat(dbFile(config.path)); err != nil {
+		return fmt.Errorf("no database to back up: %w", err)
+	}
+	db, err := sqlite.NewDB(config.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := sqlite.Backup(db, *out); err != nil {
+		return err
+	}
+	log.Printf("backed up %s to %s", config.path, *out)
+	return nil
+}
+
+// dbFile returns the file of the database at path, which can also be a
+// file: URI.
+func dbFile(path string) string {
+	file, _, _ := strings.Cut(strings.TrimPrefix(path, "file:"), "?")
+	return file
+}
+
+// restore replaces the database with a backup once it is found intact and
+// of a schema version that can be migrated to this one, and then migrates
+// it unless auto migration is off.
+func restore(args []string, config dbConfig) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := flags.String("in", "", "backup file to restore")
+	check := flags.Bool("check", false, "only check that the backup can be restored")
+	flags.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	if *check {
+		version, err := sqlite.CheckBackup(*in)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s can be restored, schema version %d", *in, version)
+		return nil
+	}
+	db, err := sqlite.NewDB(config.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	version, err := sqlite.Restore(db, *in)
+	if err != nil {
+		return err
+	}
+	log.Printf("restored %s from %s, schema version %d", config.path, *in, version)
+	if !config.migrate {
+		return nil
+	}
+	applied, err := sqlite.MigrateUp(db)
+	for _, m := range applied {
+		log.Printf("applied migration %04d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+// createAdmin bootstraps the first admin, who can then manage every other
+// user through the admin routes. An existing user is made an admin,
+// otherwise one is created with the password in MEALSHUFFLER_PASSWORD or
+// read from stdin.
+func createAdmin(args []string, config dbConfig) error {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := flags.String("username", "", "username of the admin")
+	name := flags.String("name", "", "name of the admin, defaults to the username")
+	flags.Parse(args)
+	if *username == "" {
+		return fmt.Errorf("-username is required")
+	}
+	if *name == "" {
+		*name = *username
+	}
+
+	db, err := openDB(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return makeAdmin(sqlite.NewUserService(db), *username, *name)
+}
+
+// makeAdmin makes the user an admin, first creating them if they don't
+// exist, see createAdmin.
+func makeAdmin(userService *sqlite.UserService, username, name string) error {
+	user, err := userService.UserByUserName(username)
+	if err == sql.ErrNoRows {
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+		if err != nil {
+			return err
+		}
+		user, err = userService.CreateUser(&app.NewUser{Name: name, Username: username}, hash)
+		if err != nil {
+			return err
+		}
+		log.Printf("created user %s", username)
+	} else if err != nil {
+		return err
+	}
+	if err := userService.SetUserRole(user.ID.String(), app.UserRoleAdmin); err != nil {
+		return err
+	}
+	log.Printf("%s (%s) is an admin", username, user.ID)
+	return nil
+}
+
+func readPassword() (string, error) {
+	if password := os.Getenv("MEALSHUFFLER_PASSWORD"); password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, "password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password is required")
+	}
+	return password, nil
+}
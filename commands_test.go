@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nrdev.se/mealshuffler/sqlite"
+)
+
+func TestBackupWithoutDatabase(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.db")
+	out := filepath.Join(dir, "backup.db")
+
+	if err := backup([]string{"-out", out}, dbConfig{path: missing}); err == nil {
+		t.Error("Expected backing up a missing database to fail")
+	}
+	for _, path := range []string{missing, out} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be created, got %v", path, err)
+		}
+	}
+}
+
+func TestBackupOfFileURI(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mealshuffler.db")
+	db, err := sqlite.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sqlite.MigrateUp(db)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "backup.db")
+	if err := backup([]string{"-out", out}, dbConfig{path: "file:" + path + "?cache=private"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlite.CheckBackup(out); err != nil {
+		t.Errorf("Expected a usable backup: %v", err)
+	}
+}
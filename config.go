@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix prefixes the environment variables that set flags.
+const envPrefix = "MEALSHUFFLER_"
+
+// envName returns the environment variable that sets the flag, such as
+// MEALSHUFFLER_SESSION_MAX_AGE for -session-max-age.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig gives the flags not set on the command line their value from
+// the environment, or else from the JSON config file at path, an object
+// keyed by flag name such as {"db": "/var/lib/mealshuffler/mealshuffler.db"}.
+func loadConfig(flags *flag.FlagSet, path string) error {
+	config := map[string]any{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		for name := range config {
+			if flags.Lookup(name) == nil {
+				return fmt.Errorf("unknown setting %q in config file %s", name, path)
+			}
+		}
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			configValue, ok := config[f.Name]
+			if !ok {
+				return
+			}
+			value = fmt.Sprint(configValue)
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for -%s: %w", value, f.Name, setErr)
+		}
+	})
+	return err
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -27,7 +30,32 @@ func main() {
 	// CORS origin as a command line flag
 
 	corsOrigin := flag.String("cors-origin", "*", "CORS origin")
+	flag.DurationVar(&app.SessionIdleTimeout, "session-idle-timeout", app.SessionIdleTimeout, "how long a session lasts without being used")
+	flag.DurationVar(&app.SessionMaxAge, "session-max-age", app.SessionMaxAge, "how long a session lasts at most since the login")
+	nutrients := flag.String("nutrients", "", "CSV file with nutrients per 100 g of foods to import at startup, e.g. from Livsmedelsverket")
+	autoMigrate := flag.Bool("auto-migrate", true, "apply pending database migrations at startup, otherwise run migrate up first")
+	startupAdmin := flag.String("create-admin", "", "username of an admin to create at startup, as with the create-admin command; for "+sqlite.MemoryDB+" databases")
+	dbPath := flag.String("db", "./mealshuffler.db", "SQLite database file or file: URI, or "+sqlite.MemoryDB+" for a database that is gone when the process exits")
+	backupInterval := flag.Duration("backup-interval", 0, "how often to back up the database while the server runs, e.g. 24h; 0 turns scheduled backups off")
+	backupDir := flag.String("backup-dir", "", "directory for scheduled backups, defaults to backups next to the database file")
+	backupKeep := flag.Int("backup-keep", 7, "how many scheduled backups to keep, older ones are deleted")
+	configFile := flag.String("config", os.Getenv(envName("config")), "JSON config file setting flags by name, e.g. {\"db\": \"/data/mealshuffler.db\"}; flags are also set by "+envPrefix+"<FLAG>, e.g. "+envName("db"))
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+	if err := loadConfig(flag.CommandLine, *configFile); err != nil {
+		log.Fatal(err)
+	}
+	database := dbConfig{path: *dbPath, migrate: *autoMigrate}
+
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args(), database); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	e := echo.New()
 
@@ -69,33 +97,90 @@ func main() {
 		c.JSON(httpErr.Code, httpErr)
 	}
 
-	db, err := sqlite.NewDB()
+	log.Printf("Database: %s", database.path)
+	db, err := openDB(database)
 	if err != nil {
 		e.Logger.Fatal(err)
 	}
 
+	if *backupInterval > 0 {
+		if *backupKeep < 1 {
+			e.Logger.Fatal("-backup-keep must be at least 1")
+		}
+		if *backupDir == "" {
+			*backupDir = filepath.Join(filepath.Dir(database.path), "backups")
+		}
+		log.Printf("Backing up the database to %s every %s, keeping %d", *backupDir, *backupInterval, *backupKeep)
+		go scheduleBackups(db, *backupDir, *backupInterval, *backupKeep)
+	}
+
+	nutrientService := sqlite.NewNutrientService(db)
+	if *nutrients != "" {
+		if err := importNutrients(nutrientService, *nutrients); err != nil {
+			e.Logger.Fatal(err)
+		}
+	}
+
 	recipeService := sqlite.NewRecipeService(db)
-	recipeController := api.NewRecipeController(recipeService)
+	recipeController := api.NewRecipeController(recipeService, nutrientService)
 
 	userService := sqlite.NewUserService(db)
+	if *startupAdmin != "" {
+		if err := makeAdmin(userService, *startupAdmin, *startupAdmin); err != nil {
+			e.Logger.Fatal(err)
+		}
+	}
 	weekService := sqlite.NewWeekService(db)
-	userController := api.NewUserController(userService, recipeService, weekService)
+	pantryService := sqlite.NewPantryService(db)
+	pantryController := api.NewPantryController(pantryService)
+	householdService := sqlite.NewHouseholdService(db)
+	householdController := api.NewHouseholdController(householdService, userService)
+	userController := api.NewUserController(userService, recipeService, weekService, pantryService, nutrientService, householdService)
 
 	weekController := api.NewWeekController(weekService)
+	sessionController := api.NewSessionController(userService)
+	apiTokenController := api.NewAPITokenController(userService)
 
 	srv := server{
 		userService: userService,
 	}
 	e.POST("/login", srv.login)
-
-	api := e.Group("/api")
-	api.Use(middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
-		Validator: func(key string, _ echo.Context) (bool, error) {
-			_, err := userService.ValidateUserToken(key)
+	e.POST("/logout", srv.logout)
+
+	// The api group below shadows the api package.
+	householdMiddleware := api.HouseholdMiddleware(householdService)
+	requireOwnUser, requireAdmin := api.RequireOwnUser, api.RequireAdmin
+	keyAuth := middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			var userID string
+			if strings.HasPrefix(key, app.APITokenPrefix) {
+				token, err := userService.ValidateAPIToken(key)
+				if err != nil {
+					log.Println("error validating API token: ", err)
+					return false, err
+				}
+				scope, ok := api.RequiredScope(c)
+				if !ok {
+					return false, fmt.Errorf("%w: can't be used for %s", app.ErrMissingScope, c.Path())
+				}
+				if !token.Allows(scope) {
+					return false, fmt.Errorf("%w: needs scope %s", app.ErrMissingScope, scope)
+				}
+				userID = token.UserID.String()
+			} else {
+				session, err := userService.ValidateUserToken(key)
+				if err != nil {
+					log.Println("error validating token: ", err)
+					return false, err
+				}
+				api.SetCurrentSession(c, session)
+				userID = session.UserID.String()
+			}
+			user, err := userService.User(userID)
 			if err != nil {
-				log.Println("error validating token: ", err)
 				return false, err
 			}
+			api.SetCurrentUser(c, user)
 			return true, nil
 		},
 		ErrorHandler: func(err error, _ echo.Context) error {
@@ -103,43 +188,110 @@ func main() {
 				Message: fmt.Sprintf("access denied: %s", err.Error()),
 				Code:    http.StatusUnauthorized,
 			}
+			if errors.Is(err, app.ErrMissingScope) {
+				httpErr.Code = http.StatusForbidden
+			}
 			return httpErr
 		},
-	}))
+	})
+
+	api := e.Group("/api")
+	api.Use(keyAuth)
+	api.Use(requireOwnUser)
+	api.Use(householdMiddleware)
 
 	api.GET("/ping", ping)
 
+	api.GET("/sessions", sessionController.GetSessions)
+	api.DELETE("/sessions/:id", sessionController.DeleteSession)
+	api.GET("/me", userController.GetMe)
+	api.PATCH("/me", userController.UpdateMe)
+	api.DELETE("/me", userController.DeleteMe)
+	api.POST("/me/password", userController.ChangePassword)
+	api.GET("/tokens", apiTokenController.GetAPITokens)
+	api.POST("/tokens", apiTokenController.CreateAPIToken)
+	api.DELETE("/tokens/:id", apiTokenController.DeleteAPIToken)
+
 	api.GET("/users/:id/generate", userController.GenerateWeek)
 	api.POST("/users/:id/weeks", userController.SaveWeek)
 	api.DELETE("/users/:id/weeks/:weekID", userController.DeleteWeek)
 	api.GET("/users/:id/weeks/next", userController.NextWeekNumber)
 	api.POST("/users/:id/weeks/:weekID/suggest", userController.GenerateRecipeAlternative)
+	api.GET("/users/:id/weeks/:weekID/shopping-list", userController.GetShoppingList)
+	api.GET("/users/:id/weeks/:weekID/nutrition", userController.GetWeekNutrition)
 	api.PUT("/users/:id/weeks/:weekID", userController.UpdateWeek)
 	api.PUT("/users/:id/weeks", userController.UpdateWeeks)
 	api.PUT("/users/:id/weeks/shuffle", userController.ShuffleWeekRecipes)
+	api.POST("/users/:id/weeks/draft/accept", userController.AcceptDraft)
+	api.DELETE("/users/:id/weeks/draft", userController.DiscardDraft)
+	api.GET("/users/:id/weeks/drafts", userController.GetDrafts)
+	api.POST("/users/:id/weeks/:weekID/accept", userController.AcceptWeek)
+	api.GET("/users/:id/budget/:year", userController.GetBudgetReport)
+	api.GET("/users/:id/settings", userController.GetPlannerSettings)
+	api.PUT("/users/:id/settings", userController.UpdatePlannerSettings)
+	api.GET("/users/:id/diet", userController.GetDietaryProfile)
+	api.PUT("/users/:id/diet", userController.UpdateDietaryProfile)
+	api.GET("/users/:id/household", householdController.GetHousehold)
+	api.POST("/users/:id/household/members", householdController.InviteMember)
+	api.PUT("/users/:id/household/members/:memberID", householdController.UpdateMember)
+	api.DELETE("/users/:id/household/members/:memberID", householdController.RemoveMember)
+	api.GET("/users/:id/household/invites", householdController.GetInvites)
+	api.POST("/users/:id/household/invites/:householdID/accept", householdController.AcceptInvite)
+	api.DELETE("/users/:id/household/invites/:householdID", householdController.DeclineInvite)
 	api.POST("/users/:id/recipes", recipeController.CreateRecipe)
+	api.PUT("/users/:id/recipes/:recipeID", recipeController.UpdateRecipe)
 
 	api.GET("/users/:id/recipes", recipeController.GetUserRecipes)
+	api.GET("/users/:id/recipes/stats", userController.GetRecipeStats)
+	api.GET("/users/:id/recipes/:recipeID/stats", userController.GetRecipeStat)
+
+	api.GET("/users/:id/pantry", pantryController.GetPantry)
+	api.POST("/users/:id/pantry", pantryController.CreatePantryItem)
+	api.PUT("/users/:id/pantry/:itemID", pantryController.UpdatePantryItem)
+	api.DELETE("/users/:id/pantry/:itemID", pantryController.DeletePantryItem)
 
 	api.GET("/recipes", recipeController.GetRecipes)
-	api.DELETE("/recipes", recipeController.DeleteRecipes)
-	api.PUT("/recipes", recipeController.UpdateRecipe)
+	api.DELETE("/recipes", recipeController.DeleteRecipes, requireAdmin)
+	api.PUT("/recipes", recipeController.UpdateRecipe, requireAdmin)
+	api.GET("/items", recipeController.GetItems)
 
 	api.GET("/users/:id/weeks/:year", weekController.GetWeeks)
 	api.GET("/users/:id/weeks/last", weekController.GetLastGeneratedWeek)
 	api.DELETE("/users/:id/weeks/:year/all", weekController.DeleteWeeks)
 
 	admin := e.Group("/api/admin")
-	admin.Use(srv.AdminMiddleware)
+	admin.Use(keyAuth)
+	admin.Use(requireAdmin)
 	admin.GET("/ping", ping)
 	admin.POST("/users", userController.CreateUser)
 	admin.GET("/users", userController.GetUsers)
 	admin.GET("/users/:id", userController.GetUser)
 	admin.DELETE("/users/:id", userController.DeleteUser)
+	admin.PUT("/users/:id/role", userController.UpdateUserRole)
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
 
+// importNutrients adds the foods in the CSV file at path to the nutrient
+// table.
+func importNutrients(nutrientService app.NutrientService, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	foods, err := app.ParseFoodsCSV(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	count, err := nutrientService.ImportFoods(foods)
+	if err != nil {
+		return err
+	}
+	log.Printf("imported %d foods from %s", count, path)
+	return nil
+}
+
 func ping(c echo.Context) error {
 	return c.String(http.StatusOK, "pong")
 }
@@ -148,6 +300,9 @@ func (s *server) login(c echo.Context) error {
 	type user struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		// Label tells the session apart from those on other devices,
+		// defaults to the user agent.
+		Label string `json:"label"`
 	}
 	var u user
 	if err := c.Bind(&u); err != nil {
@@ -191,7 +346,11 @@ func (s *server) login(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, httpErr)
 	}
-	err = s.userService.SaveUserToken(dbUser.ID.String(), newBearerToken)
+	label := u.Label
+	if label == "" {
+		label = c.Request().UserAgent()
+	}
+	session, err := s.userService.CreateSession(dbUser.ID.String(), newBearerToken, label)
 	if err != nil {
 		httpErr := app.HTTPError{
 			Message: err.Error(),
@@ -204,9 +363,37 @@ func (s *server) login(c echo.Context) error {
 		"token":      newBearerToken,
 		"token_type": "bearer",
 		"user":       dbUser,
+		"session":    session,
 	})
 }
 
+// logout ends the session of the bearer token of the request.
+func (s *server) logout(c echo.Context) error {
+	token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		httpErr := app.HTTPError{
+			Message: "access denied: missing key in request header",
+			Code:    http.StatusUnauthorized,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	if err := s.userService.DeleteSessionByToken(token); err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			httpErr := app.HTTPError{
+				Message: "access denied: no such session",
+				Code:    http.StatusUnauthorized,
+			}
+			return c.JSON(httpErr.Code, httpErr)
+		}
+		httpErr := app.HTTPError{
+			Message: err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+		return c.JSON(httpErr.Code, httpErr)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
 func checkRequestContentTypeJSON(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		if c.Request().Method == http.MethodGet {
@@ -242,22 +429,3 @@ func generateBearerToken(length int) (string, error) {
 
 	return token, nil
 }
-func (s *server) AdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		token := c.Request().Header.Get("Authorization")
-		token = strings.TrimPrefix(token, "Bearer ")
-		adminToken, err := s.userService.GetUserToken("cjiq")
-		if err != nil {
-			httpErr := app.HTTPError{
-				Message: err.Error(),
-				Code:    http.StatusInternalServerError,
-			}
-			return c.JSON(http.StatusInternalServerError, httpErr)
-		}
-
-		if token != adminToken {
-			return c.String(http.StatusUnauthorized, "Unauthorized")
-		}
-		return next(c)
-	}
-}
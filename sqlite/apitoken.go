@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func (us *UserService) CreateAPIToken(userID string, newToken *app.NewAPIToken, token string) (*app.APIToken, error) {
+	apiToken := &app.APIToken{
+		NewAPIToken: *newToken,
+		Token:       token,
+		CreatedAt:   time.Now().UTC(),
+		Entity:      app.Entity{ID: uuid.New()},
+	}
+	var err error
+	apiToken.UserID, err = uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = us.db.Exec(`INSERT INTO api_token(
+		id, user_id, name, token_hash, scopes, created_at
+	)
+	VALUES(?, ?, ?, ?, ?, ?)
+	`, apiToken.ID.String(), userID, newToken.Name, app.HashToken(token), joinScopes(newToken.Scopes), apiToken.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return apiToken, nil
+}
+
+func (us *UserService) APITokens(userID string) ([]*app.APIToken, error) {
+	rows, err := us.db.Query(`SELECT
+		id, user_id, name, scopes, created_at, last_used_at
+	FROM api_token
+	WHERE user_id = ?
+	ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []*app.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// ValidateAPIToken returns the API token, noting that it has been used.
+func (us *UserService) ValidateAPIToken(token string) (*app.APIToken, error) {
+	apiToken, err := scanAPIToken(us.db.QueryRow(`SELECT
+		id, user_id, name, scopes, created_at, last_used_at
+	FROM api_token
+	WHERE token_hash = ?`, app.HashToken(token)))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > touchInterval {
+		if _, err := us.db.Exec("UPDATE api_token SET last_used_at = ? WHERE id = ?", now, apiToken.ID.String()); err != nil {
+			return nil, err
+		}
+		apiToken.LastUsedAt = &now
+	}
+	return apiToken, nil
+}
+
+// DeleteAPIToken revokes an API token of the user, returning sql.ErrNoRows
+// if the user has no such token.
+func (us *UserService) DeleteAPIToken(userID string, id string) error {
+	result, err := us.db.Exec("DELETE FROM api_token WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanAPIToken(row interface{ Scan(...any) error }) (*app.APIToken, error) {
+	var token app.APIToken
+	var scopes string
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	token.Scopes = splitScopes(scopes)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return &token, nil
+}
+
+func joinScopes(scopes []app.Scope) string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitScopes(s string) []app.Scope {
+	scopes := []app.Scope{}
+	for _, scope := range strings.Split(s, ",") {
+		if scope != "" {
+			scopes = append(scopes, app.Scope(scope))
+		}
+	}
+	return scopes
+}
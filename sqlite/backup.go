@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backup writes a consistent copy of the database to path, replacing any
+// file there. It is safe while the database is in use: the copy is made in
+// a read transaction, which doesn't hold up writes, into a temporary file
+// that is renamed to path once complete.
+func Backup(db *sql.DB, path string) error {
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if _, err := db.Exec("VACUUM INTO ?", tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to back up database to %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// CheckBackup checks that the file at path is an intact database with a
+// schema this version can migrate, returning its schema version. That is 0
+// for databases from before migrations, which are upgraded by the first
+// one.
+func CheckBackup(path string) (int, error) {
+	backup, err := openBackup(path)
+	if err != nil {
+		return 0, err
+	}
+	defer backup.Close()
+	return checkBackup(backup, path)
+}
+
+// openBackup opens the backup at path read-only.
+func openBackup(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite3", "file:"+path+"?mode=ro")
+}
+
+func checkBackup(backup *sql.DB, path string) (int, error) {
+	var check string
+	if err := backup.QueryRow("PRAGMA quick_check").Scan(&check); err != nil {
+		return 0, fmt.Errorf("%s is not a database: %w", path, err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("%s is damaged: %s", path, check)
+	}
+	version, err := SchemaVersion(backup)
+	if err != nil {
+		return 0, err
+	}
+	migrations, err := migrations()
+	if err != nil {
+		return 0, err
+	}
+	latest := migrations[len(migrations)-1].Version
+	if version == 0 {
+		legacy, err := hasLegacyTables(backup)
+		if err != nil {
+			return 0, err
+		}
+		if !legacy {
+			return 0, fmt.Errorf("%s has no schema version and none of the tables of a mealshuffler database", path)
+		}
+	}
+	if version > latest {
+		return 0, fmt.Errorf("%s has schema version %d, newer than the %d of this version", path, version, latest)
+	}
+	return version, nil
+}
+
+// Restore replaces the content of the database with the backup at path,
+// after checking it with CheckBackup. The pages are copied with the online
+// backup API, which takes the locks of the database, so that connections
+// already open see the restored database. A backup with an older schema
+// version is left to be migrated up. Returns the schema version of the
+// backup.
+func Restore(db *sql.DB, path string) (int, error) {
+	backup, err := openBackup(path)
+	if err != nil {
+		return 0, err
+	}
+	defer backup.Close()
+	version, err := checkBackup(backup, path)
+	if err != nil {
+		return 0, err
+	}
+	if err := copyDB(db, backup); err != nil {
+		return 0, fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+	return version, nil
+}
+
+// copyDB copies every page of src to dst, retrying while dst is locked by
+// other connections for up to BusyTimeout.
+func copyDB(dst, src *sql.DB) error {
+	ctx := context.Background()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	return dstConn.Raw(func(dstDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			backup, err := dstDriverConn.(*sqlite3.SQLiteConn).Backup("main", srcDriverConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			deadline := time.Now().Add(BusyTimeout)
+			for {
+				done, err := backup.Step(-1)
+				if err != nil {
+					backup.Finish()
+					return err
+				}
+				if done {
+					return backup.Finish()
+				}
+				if time.Now().After(deadline) {
+					backup.Finish()
+					return fmt.Errorf("database is locked")
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+		})
+	})
+}
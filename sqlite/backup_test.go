@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	users := NewUserService(db)
+	if _, err := users.CreateUser(&app.NewUser{Name: "Ann", Username: "ann"}, []byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := Backup(db, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Backup(db, path); err != nil {
+		t.Fatalf("Expected an existing backup to be replaced: %v", err)
+	}
+	if version, err := CheckBackup(path); err != nil || version != latestVersion(t) {
+		t.Fatalf("Expected the backup to be at the latest schema version, got %d, %v", version, err)
+	}
+
+	if _, err := users.CreateUser(&app.NewUser{Name: "Bo", Username: "bo"}, []byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(db, path); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := users.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 1 || restored[0].Name != "Ann" {
+		t.Errorf("Expected the users at the time of the backup, got %+v", restored)
+	}
+}
+
+func TestRestoreChecksSchemaVersion(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	newer := filepath.Join(dir, "newer.db")
+	if err := Backup(db, newer); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := NewDB(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = backup.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', CURRENT_TIMESTAMP)", latestVersion(t)+1)
+	backup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(db, newer); err == nil {
+		t.Error("Expected a backup with a newer schema version to be refused")
+	}
+
+	empty, err := NewDB(filepath.Join(dir, "empty.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty.Close()
+	if _, err := CheckBackup(filepath.Join(dir, "empty.db")); err == nil {
+		t.Error("Expected a database without migrations to be refused")
+	}
+	if _, err := CheckBackup(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected a missing backup to be refused")
+	}
+
+	if version, err := SchemaVersion(db); err != nil || version != latestVersion(t) {
+		t.Errorf("Expected the database to be left as it was, got version %d, %v", version, err)
+	}
+}
+
+func TestRestoreLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.Exec(legacySchema)
+	legacy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := CheckBackup(path); err != nil || version != 0 {
+		t.Fatalf("Expected a database from before migrations to be accepted, got %d, %v", version, err)
+	}
+
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(db, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := SchemaVersion(db); err != nil || version != latestVersion(t) {
+		t.Errorf("Expected the restored database to be migrated, got %d, %v", version, err)
+	}
+	if _, err := NewUserService(db).ValidateUserToken("secret"); err != nil {
+		t.Errorf("Expected the user of the legacy database to be restored: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// dateFormat is how the dates of days are stored, so that they compare as
+// text.
+const dateFormat = "2006-01-02"
+
+// checkRecipes returns app.ErrUnknownRecipe if a meal of days is a recipe
+// the household can't plan.
+func checkRecipes(tx *sql.Tx, householdID string, days []*app.Day) error {
+	for _, day := range days {
+		for _, meal := range day.Meals {
+			if meal.Recipe == nil {
+				continue
+			}
+			var found int
+			err := tx.QueryRow("SELECT COUNT(*) FROM recipe WHERE id = ? AND "+ownedBy,
+				meal.Recipe.ID, householdID).Scan(&found)
+			if err != nil {
+				return err
+			}
+			if found == 0 {
+				return fmt.Errorf("%w: %s", app.ErrUnknownRecipe, meal.Recipe.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// saveDays replaces the days of the week with days.
+func saveDays(tx *sql.Tx, weekID uuid.UUID, householdID string, days []*app.Day) error {
+	if err := checkRecipes(tx, householdID, days); err != nil {
+		return err
+	}
+	if err := deleteDays(tx, "id = ?", weekID); err != nil {
+		return err
+	}
+	return insertDays(tx, weekID, days)
+}
+
+// insertDays stores days as the days of the week in the order given. Days
+// without an id are given one.
+func insertDays(tx *sql.Tx, weekID uuid.UUID, days []*app.Day) error {
+	for i, day := range days {
+		if day.ID == uuid.Nil {
+			day.ID = uuid.New()
+		}
+		_, err := tx.Exec("INSERT INTO day (id, week_id, date, position) VALUES (?, ?, ?, ?)",
+			day.ID, weekID, day.Date.Format(dateFormat), i)
+		if err != nil {
+			return err
+		}
+		for _, slot := range day.Slots() {
+			meal := day.Meals[slot]
+			if meal.Recipe == nil {
+				continue
+			}
+			_, err := tx.Exec(`INSERT INTO meal (week_id, day_id, slot, recipe_id, leftover_of)
+			VALUES (?, ?, ?, ?, ?)`, weekID, day.ID, slot, meal.Recipe.ID, uuid.NullUUID{
+				UUID:  derefUUID(meal.LeftoverOf),
+				Valid: meal.LeftoverOf != nil,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func derefUUID(id *uuid.UUID) uuid.UUID {
+	if id == nil {
+		return uuid.Nil
+	}
+	return *id
+}
+
+// deleteDays deletes the days of the weeks matching the condition on the
+// week table.
+func deleteDays(tx *sql.Tx, weekCondition string, args ...any) error {
+	weeks := "SELECT id FROM week WHERE " + weekCondition
+	if _, err := tx.Exec("DELETE FROM meal WHERE week_id IN ("+weeks+")", args...); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM day WHERE week_id IN ("+weeks+")", args...)
+	return err
+}
+
+// loadDays fills in the days of weeks, planned with the current version of
+// the recipes of the household. Meals of other recipes are left out and
+// logged.
+func (ws *WeekService) loadDays(householdID string, weeks ...*app.Week) error {
+	if len(weeks) == 0 {
+		return nil
+	}
+	recipes, err := ws.recipes.HouseholdRecipes(householdID)
+	if err != nil {
+		return err
+	}
+	recipesByID := map[uuid.UUID]*app.Recipe{}
+	for _, recipe := range recipes {
+		recipesByID[recipe.ID] = recipe
+	}
+	weeksByID := map[uuid.UUID]*app.Week{}
+	args := make([]any, len(weeks))
+	for i, week := range weeks {
+		week.Days = []*app.Day{}
+		weeksByID[week.ID] = week
+		args[i] = week.ID
+	}
+
+	rows, err := ws.db.Query(`SELECT
+		d.week_id, d.id, d.date, m.slot, m.recipe_id, m.leftover_of
+	FROM day d
+	LEFT JOIN meal m ON m.week_id = d.week_id AND m.day_id = d.id
+	WHERE d.week_id IN (?`+strings.Repeat(", ?", len(weeks)-1)+`)
+	ORDER BY d.week_id, d.position
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var day *app.Day
+	var dayWeekID uuid.UUID
+	for rows.Next() {
+		var weekID, dayID uuid.UUID
+		var date time.Time
+		var slot sql.NullString
+		var recipeID, leftoverOf uuid.NullUUID
+		if err := rows.Scan(&weekID, &dayID, &date, &slot, &recipeID, &leftoverOf); err != nil {
+			return err
+		}
+		if day == nil || dayWeekID != weekID || day.ID != dayID {
+			day = &app.Day{Date: date, Entity: app.Entity{ID: dayID}}
+			dayWeekID = weekID
+			week := weeksByID[weekID]
+			week.Days = append(week.Days, day)
+		}
+		if !slot.Valid {
+			continue
+		}
+		recipe := recipesByID[recipeID.UUID]
+		if recipe == nil {
+			log.Printf("leaving out the %s of week %s on %s, recipe %s is not one of household %s",
+				slot.String, weekID, date.Format(dateFormat), recipeID.UUID, householdID)
+			continue
+		}
+		// Every meal gets its own copy, days are edited one by one.
+		planned := *recipe
+		day.SetRecipe(app.MealSlot(slot.String), &planned)
+		if leftoverOf.Valid {
+			day.Meals[app.MealSlot(slot.String)].LeftoverOf = &leftoverOf.UUID
+		}
+	}
+	return rows.Err()
+}
+
+// RecipeStats tells how often and when the recipes of the household have
+// been eaten in accepted weeks, counting days up to today.
+func (ws *WeekService) RecipeStats(householdID string, today time.Time) ([]*app.RecipeStats, error) {
+	rows, err := ws.db.Query(recipeStatsQuery(""), recipeStatsArgs(householdID, today)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []*app.RecipeStats{}
+	for rows.Next() {
+		s, err := scanRecipeStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// RecipeStat returns the stats of a recipe of the household, see
+// RecipeStats.
+func (ws *WeekService) RecipeStat(recipeID string, householdID string, today time.Time) (*app.RecipeStats, error) {
+	args := append(recipeStatsArgs(householdID, today), recipeID)
+	return scanRecipeStats(ws.db.QueryRow(recipeStatsQuery("AND r.id = ?"), args...))
+}
+
+func recipeStatsQuery(condition string) string {
+	return `SELECT
+		r.id, r.name,
+		COUNT(CASE WHEN d.date <= ? THEN 1 END),
+		MAX(CASE WHEN d.date <= ? THEN d.date END),
+		MIN(CASE WHEN d.date > ? THEN d.date END)
+	FROM recipe r
+	LEFT JOIN meal m ON m.recipe_id = r.id
+		AND m.week_id IN (SELECT id FROM week WHERE household_id = ? AND status = ?)
+	LEFT JOIN day d ON d.week_id = m.week_id AND d.id = m.day_id
+	WHERE ` + ownedBy + ` ` + condition + `
+	GROUP BY r.id, r.name
+	ORDER BY r.name, r.id`
+}
+
+func recipeStatsArgs(householdID string, today time.Time) []any {
+	date := today.Format(dateFormat)
+	return []any{date, date, date, householdID, app.WeekAccepted, householdID}
+}
+
+func scanRecipeStats(row interface{ Scan(...any) error }) (*app.RecipeStats, error) {
+	var stats app.RecipeStats
+	var lastEaten, nextPlanned sql.NullString
+	if err := row.Scan(&stats.RecipeID, &stats.Name, &stats.TimesEaten, &lastEaten, &nextPlanned); err != nil {
+		return nil, err
+	}
+	var err error
+	if stats.LastEaten, err = parseDate(lastEaten); err != nil {
+		return nil, err
+	}
+	if stats.NextPlanned, err = parseDate(nextPlanned); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// parseDate parses dates that come back as text, such as aggregates of the
+// date column.
+func parseDate(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	date, err := time.Parse(dateFormat, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
@@ -2,15 +2,92 @@ package sqlite
 
 import (
 	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func NewDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "./mealshuffler.db")
+// MemoryDB is the path of a database kept in memory, which is gone when the
+// process exits. Meant for tests and demos.
+const MemoryDB = ":memory:"
+
+// BusyTimeout is how long a write waits for another write to finish before
+// failing with "database is locked".
+var BusyTimeout = 5 * time.Second
+
+// NewDB opens the database at path, creating it if it doesn't exist, or a
+// new in-memory database for MemoryDB. Foreign keys are enforced, and files
+// use write-ahead logging so that reads don't wait for writes.
+func NewDB(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", strconv.FormatInt(BusyTimeout.Milliseconds(), 10))
+	// Transactions take the write lock when they begin, so that they wait
+	// for other writes instead of failing when they go from reading to
+	// writing.
+	params.Set("_txlock", "immediate")
+	if path != MemoryDB {
+		params.Set("_journal_mode", "WAL")
+	}
+	// path can be a file: URI with parameters of its own, which then take
+	// precedence.
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("sqlite3", path+separator+params.Encode())
 	if err != nil {
 		return nil, err
 	}
-
+	if path == MemoryDB {
+		// Every connection would get an empty database of its own.
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
 	return db, nil
 }
+
+// queryer is what *sql.DB and *sql.Tx have in common.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// hasColumn reports whether table has column.
+func hasColumn(db queryer, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumn adds column to table unless it already exists, so that tables
+// created by an earlier version pick up new columns.
+func addColumn(db queryer, table, column, definition string) error {
+	if ok, err := hasColumn(db, table, column); err != nil || ok {
+		return err
+	}
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
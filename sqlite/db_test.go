@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func TestNewDBPragmas(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(dir, "plain.db"),
+		"file:" + filepath.Join(dir, "uri.db"),
+		"file:" + filepath.Join(dir, "query.db") + "?cache=private",
+	} {
+		db, err := NewDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var journalMode string
+		var foreignKeys, busyTimeout int
+		if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		if journalMode != "wal" || foreignKeys != 1 || busyTimeout != int(BusyTimeout.Milliseconds()) {
+			t.Errorf("Expected WAL, foreign keys and a busy timeout for %s, got journal_mode %s, foreign_keys %d, busy_timeout %d",
+				path, journalMode, foreignKeys, busyTimeout)
+		}
+	}
+	for _, name := range []string{"plain.db", "uri.db", "query.db"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected the database to be created as %s: %v", name, err)
+		}
+	}
+}
+
+func TestNewDBInMemory(t *testing.T) {
+	db, err := NewDB(MemoryDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	users := NewUserService(db)
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			username := string(rune('a' + i))
+			_, err := users.CreateUser(&app.NewUser{Name: username, Username: username}, []byte("hash"))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := users.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != cap(errs) {
+		t.Errorf("Expected every connection to see the same database with %d users, got %d", cap(errs), len(all))
+	}
+
+	other, err := NewDB(MemoryDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if version, err := SchemaVersion(other); err != nil || version != 0 {
+		t.Errorf("Expected every in-memory database to start out empty, got version %d, %v", version, err)
+	}
+}
+
+func TestNewDBInMemoryAfterFailedTransaction(t *testing.T) {
+	db, err := NewDB(MemoryDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("ALTER TABLE week RENAME TO gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWeekService(db).UpdateWeek(&app.Week{}, ""); err == nil {
+		t.Fatal("Expected the update to fail without a week table")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewUserService(db).Users()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the failed update to give back the only connection")
+	}
+}
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func (us *UserService) DietaryProfile(userID string) (*app.DietaryProfile, error) {
+	profile := &app.DietaryProfile{}
+	var allergens, ingredients, tags string
+	err := us.db.QueryRow(`SELECT
+		vegetarian, gluten_free, lactose_free, allergens, excluded_ingredients, excluded_tags
+	FROM dietary_profile
+	WHERE user_id = ?`, userID).Scan(&profile.Vegetarian, &profile.GlutenFree, &profile.LactoseFree, &allergens, &ingredients, &tags)
+	if err == sql.ErrNoRows {
+		return profile, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	profile.Allergens = splitAllergens(allergens)
+	if err := json.Unmarshal([]byte(ingredients), &profile.ExcludedIngredients); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &profile.ExcludedTags); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (us *UserService) SaveDietaryProfile(userID string, profile *app.DietaryProfile) error {
+	ingredients, err := json.Marshal(append([]string{}, profile.ExcludedIngredients...))
+	if err != nil {
+		return err
+	}
+	tags, err := json.Marshal(append([]app.Tag{}, profile.ExcludedTags...))
+	if err != nil {
+		return err
+	}
+	_, err = us.db.Exec(`INSERT INTO dietary_profile(
+		user_id, vegetarian, gluten_free, lactose_free, allergens, excluded_ingredients, excluded_tags
+	)
+	VALUES(?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		vegetarian = excluded.vegetarian,
+		gluten_free = excluded.gluten_free,
+		lactose_free = excluded.lactose_free,
+		allergens = excluded.allergens,
+		excluded_ingredients = excluded.excluded_ingredients,
+		excluded_tags = excluded.excluded_tags
+	`, userID, profile.Vegetarian, profile.GlutenFree, profile.LactoseFree, joinAllergens(profile.Allergens), string(ingredients), string(tags))
+	return err
+}
@@ -0,0 +1,302 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+type HouseholdService struct {
+	db *sql.DB
+}
+
+func NewHouseholdService(db *sql.DB) *HouseholdService {
+	return &HouseholdService{db: db}
+}
+
+// createHousehold creates a household named name with the user as its
+// owner.
+func createHousehold(tx *sql.Tx, name, userID string) (*app.Member, error) {
+	id := uuid.New()
+	if _, err := tx.Exec("INSERT INTO household(id, name) VALUES(?, ?)", id.String(), name); err != nil {
+		return nil, err
+	}
+	_, err := tx.Exec(`INSERT INTO household_member(user_id, household_id, role)
+	VALUES(?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		household_id = excluded.household_id,
+		role = excluded.role
+	`, userID, id.String(), app.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	member := &app.Member{HouseholdID: id, Role: app.RoleOwner}
+	member.UserID, err = uuid.Parse(userID)
+	return member, err
+}
+
+func (hs *HouseholdService) Membership(userID string) (*app.Member, error) {
+	var member app.Member
+	err := hs.db.QueryRow(`SELECT
+		m.household_id, m.user_id, u.name, m.role
+	FROM household_member m
+	JOIN user u ON u.id = m.user_id
+	WHERE m.user_id = ?`, userID).Scan(&member.HouseholdID, &member.UserID, &member.Name, &member.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (hs *HouseholdService) Household(id string) (*app.Household, error) {
+	var household app.Household
+	err := hs.db.QueryRow("SELECT id, name FROM household WHERE id = ?", id).Scan(&household.ID, &household.Name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := hs.db.Query(`SELECT
+		m.household_id, m.user_id, u.name, m.role
+	FROM household_member m
+	JOIN user u ON u.id = m.user_id
+	WHERE m.household_id = ?
+	ORDER BY u.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	household.Members = []*app.Member{}
+	for rows.Next() {
+		var member app.Member
+		if err := rows.Scan(&member.HouseholdID, &member.UserID, &member.Name, &member.Role); err != nil {
+			return nil, err
+		}
+		household.Members = append(household.Members, &member)
+	}
+	return &household, rows.Err()
+}
+
+func (hs *HouseholdService) InviteMember(householdID, userID string, role app.HouseholdRole) (*app.Invite, error) {
+	var current string
+	err := hs.db.QueryRow("SELECT household_id FROM household_member WHERE user_id = ?", userID).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if current == householdID {
+		return nil, app.ErrAlreadyMember
+	}
+	invite := &app.Invite{Role: role, CreatedAt: time.Now().UTC()}
+	if invite.HouseholdID, err = uuid.Parse(householdID); err != nil {
+		return nil, err
+	}
+	if invite.UserID, err = uuid.Parse(userID); err != nil {
+		return nil, err
+	}
+	_, err = hs.db.Exec(`INSERT INTO household_invite(household_id, user_id, role, created_at)
+	VALUES(?, ?, ?, ?)
+	ON CONFLICT(household_id, user_id) DO UPDATE SET
+		role = excluded.role,
+		created_at = excluded.created_at
+	`, householdID, userID, role, invite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (hs *HouseholdService) Invites(userID string) ([]*app.Invite, error) {
+	rows, err := hs.db.Query(`SELECT
+		i.household_id, h.name, i.user_id, i.role, i.created_at
+	FROM household_invite i
+	JOIN household h ON h.id = i.household_id
+	WHERE i.user_id = ?
+	ORDER BY i.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	invites := []*app.Invite{}
+	for rows.Next() {
+		var invite app.Invite
+		if err := rows.Scan(&invite.HouseholdID, &invite.HouseholdName, &invite.UserID, &invite.Role, &invite.CreatedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, &invite)
+	}
+	return invites, rows.Err()
+}
+
+func (hs *HouseholdService) AcceptInvite(householdID, userID string) (*app.Member, error) {
+	tx, err := hs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var role app.HouseholdRole
+	err = tx.QueryRow("SELECT role FROM household_invite WHERE household_id = ? AND user_id = ?", householdID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return nil, app.ErrNoInvite
+	}
+	if err != nil {
+		return nil, err
+	}
+	var current string
+	err = tx.QueryRow("SELECT household_id FROM household_member WHERE user_id = ?", userID).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if current == householdID {
+		return nil, app.ErrAlreadyMember
+	}
+	if current != "" {
+		members, owners, err := otherMembers(tx, current, userID)
+		if err != nil {
+			return nil, err
+		}
+		if members > 0 && owners == 0 {
+			return nil, app.ErrLastOwner
+		}
+		if members == 0 {
+			if err := mergeHousehold(tx, current, householdID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM household_member WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM household_invite WHERE household_id = ? AND user_id = ?", householdID, userID); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("INSERT INTO household_member(user_id, household_id, role) VALUES(?, ?, ?)", userID, householdID, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hs.Membership(userID)
+}
+
+func (hs *HouseholdService) DeclineInvite(householdID, userID string) error {
+	res, err := hs.db.Exec("DELETE FROM household_invite WHERE household_id = ? AND user_id = ?", householdID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return app.ErrNoInvite
+	}
+	return nil
+}
+
+// mergeHousehold moves the recipes, weeks and pantry of the household from
+// into the household into and deletes from. Accepted weeks of from for iso
+// weeks into already has a plan for are archived.
+func mergeHousehold(tx *sql.Tx, from, into string) error {
+	_, err := tx.Exec(`UPDATE week
+	SET status = ?, updated_at = ?
+	WHERE household_id = ? AND status = ? AND EXISTS (
+		SELECT 1 FROM week w
+		WHERE w.household_id = ? AND w.status = ? AND w.year = week.year AND w.number = week.number
+	)`, app.WeekArchived, time.Now().UTC(), from, app.WeekAccepted, into, app.WeekAccepted)
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		"UPDATE recipe SET household_id = ? WHERE household_id = ?",
+		"UPDATE week SET household_id = ? WHERE household_id = ?",
+		"UPDATE pantry SET household_id = ? WHERE household_id = ?",
+	} {
+		if _, err := tx.Exec(query, into, from); err != nil {
+			return err
+		}
+	}
+	return deleteHousehold(tx, from)
+}
+
+func (hs *HouseholdService) UpdateMember(householdID, userID string, role app.HouseholdRole) (*app.Member, error) {
+	tx, err := hs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if role != app.RoleOwner {
+		if _, owners, err := otherMembers(tx, householdID, userID); err != nil {
+			return nil, err
+		} else if owners == 0 {
+			return nil, app.ErrLastOwner
+		}
+	}
+	res, err := tx.Exec("UPDATE household_member SET role = ? WHERE user_id = ? AND household_id = ?", role, userID, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, app.ErrNotMember
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hs.Membership(userID)
+}
+
+func (hs *HouseholdService) RemoveMember(householdID, userID string) error {
+	tx, err := hs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var name string
+	err = tx.QueryRow(`SELECT u.name
+	FROM household_member m
+	JOIN user u ON u.id = m.user_id
+	WHERE m.user_id = ? AND m.household_id = ?`, userID, householdID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return app.ErrNotMember
+	}
+	if err != nil {
+		return err
+	}
+	members, owners, err := otherMembers(tx, householdID, userID)
+	if err != nil {
+		return err
+	}
+	if members == 0 {
+		return app.ErrLastMember
+	}
+	if owners == 0 {
+		return app.ErrLastOwner
+	}
+	if _, err := createHousehold(tx, name, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// otherMembers counts the members and owners of the household besides the
+// user.
+func otherMembers(tx *sql.Tx, householdID, userID string) (members, owners int, err error) {
+	err = tx.QueryRow(`SELECT
+		COUNT(*),
+		COUNT(CASE WHEN role = ? THEN 1 END)
+	FROM household_member
+	WHERE household_id = ? AND user_id != ?`, app.RoleOwner, householdID, userID).Scan(&members, &owners)
+	return members, owners, err
+}
+
+// checkOwnerLeft returns app.ErrLastOwner if the household would be left
+// with members but no owner without the user.
+func checkOwnerLeft(tx *sql.Tx, householdID, userID string) error {
+	members, owners, err := otherMembers(tx, householdID, userID)
+	if err != nil {
+		return err
+	}
+	if members > 0 && owners == 0 {
+		return app.ErrLastOwner
+	}
+	return nil
+}
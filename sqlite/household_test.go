@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// householdUser creates a user in a household of their own and returns
+// their membership.
+func householdUser(t *testing.T, db *sql.DB, username string) *app.Member {
+	t.Helper()
+	user, err := NewUserService(db).CreateUser(&app.NewUser{Name: username, Username: username}, []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := NewHouseholdService(db).Membership(user.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return member
+}
+
+func TestInviteMember(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	hs := NewHouseholdService(db)
+	ann := householdUser(t, db, "ann")
+	bob := householdUser(t, db, "bob")
+	household, bobID := ann.HouseholdID.String(), bob.UserID.String()
+
+	if _, err := hs.InviteMember(household, ann.UserID.String(), app.RoleEditor); !errors.Is(err, app.ErrAlreadyMember) {
+		t.Errorf("Expected ErrAlreadyMember inviting a member, got %v", err)
+	}
+	if _, err := hs.InviteMember(household, bobID, app.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if member, err := hs.Membership(bobID); err != nil || member.HouseholdID != bob.HouseholdID {
+		t.Fatalf("Expected the invite to leave bob in his household, got %+v, %v", member, err)
+	}
+	invites, err := hs.Invites(bobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 1 || invites[0].HouseholdID != ann.HouseholdID || invites[0].HouseholdName != "ann" || invites[0].Role != app.RoleEditor {
+		t.Fatalf("Expected one invite to the household of ann, got %+v", invites)
+	}
+
+	if err := hs.DeclineInvite(household, bobID); err != nil {
+		t.Fatal(err)
+	}
+	if err := hs.DeclineInvite(household, bobID); !errors.Is(err, app.ErrNoInvite) {
+		t.Errorf("Expected ErrNoInvite declining twice, got %v", err)
+	}
+	if _, err := hs.AcceptInvite(household, bobID); !errors.Is(err, app.ErrNoInvite) {
+		t.Errorf("Expected ErrNoInvite accepting a declined invite, got %v", err)
+	}
+}
+
+func TestAcceptInviteMovesWhatTheUserOwned(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	hs, rs, ws := NewHouseholdService(db), NewRecipeService(db), NewWeekService(db)
+	ann := householdUser(t, db, "ann")
+	bob := householdUser(t, db, "bob")
+	household, old := ann.HouseholdID.String(), bob.HouseholdID.String()
+	if _, err := rs.CreateRecipe(&app.NewRecipe{Name: "Soup", Portions: 4, ProbabilityWeight: 1}, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.CreateWeek(&app.NewWeek{Number: 1, Year: 2024}, household); err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range []int{1, 2} {
+		if _, err := ws.CreateWeek(&app.NewWeek{Number: number, Year: 2024}, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := hs.InviteMember(household, bob.UserID.String(), app.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	member, err := hs.AcceptInvite(household, bob.UserID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.HouseholdID != ann.HouseholdID || member.Role != app.RoleViewer {
+		t.Errorf("Expected bob to be a viewer in the household of ann, got %+v", member)
+	}
+	if invites, err := hs.Invites(bob.UserID.String()); err != nil || len(invites) != 0 {
+		t.Errorf("Expected the invite to be used up, got %+v, %v", invites, err)
+	}
+
+	if recipes, err := rs.HouseholdRecipes(household); err != nil || len(recipes) != 1 {
+		t.Errorf("Expected the recipe of bob to move along, got %d, %v", len(recipes), err)
+	}
+	weeks, err := ws.Weeks(household, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weeks) != 2 || weeks[0].Number != 1 || weeks[1].Number != 2 {
+		t.Errorf("Expected one accepted week each for week 1 and 2, got %d", len(weeks))
+	}
+	if archived, err := ws.ArchivedWeeks(household, 2024); err != nil || len(archived) != 1 || archived[0].Number != 1 {
+		t.Errorf("Expected the week 1 of bob to be archived, got %+v, %v", archived, err)
+	}
+	if _, err := hs.Household(old); err != sql.ErrNoRows {
+		t.Errorf("Expected the empty household of bob to be deleted, got %v", err)
+	}
+}
+
+func TestAcceptInviteKeepsAnOwner(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	hs := NewHouseholdService(db)
+	ann := householdUser(t, db, "ann")
+	bob := householdUser(t, db, "bob")
+	cid := householdUser(t, db, "cid")
+	household := ann.HouseholdID.String()
+	if _, err := hs.InviteMember(household, bob.UserID.String(), app.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hs.AcceptInvite(household, bob.UserID.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hs.InviteMember(cid.HouseholdID.String(), ann.UserID.String(), app.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hs.AcceptInvite(cid.HouseholdID.String(), ann.UserID.String()); !errors.Is(err, app.ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner leaving bob without an owner, got %v", err)
+	}
+	if member, err := hs.Membership(ann.UserID.String()); err != nil || member.HouseholdID != ann.HouseholdID {
+		t.Errorf("Expected ann to stay in her household, got %+v, %v", member, err)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	hs, rs := NewHouseholdService(db), NewRecipeService(db)
+	ann := householdUser(t, db, "ann")
+	bob := householdUser(t, db, "bob")
+	household := ann.HouseholdID.String()
+	if _, err := rs.CreateRecipe(&app.NewRecipe{Name: "Soup", Portions: 4, ProbabilityWeight: 1}, household); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hs.RemoveMember(household, ann.UserID.String()); !errors.Is(err, app.ErrLastMember) {
+		t.Errorf("Expected ErrLastMember removing the only member, got %v", err)
+	}
+	if member, err := hs.Membership(ann.UserID.String()); err != nil || member.HouseholdID != ann.HouseholdID {
+		t.Errorf("Expected ann to stay in her household, got %+v, %v", member, err)
+	}
+
+	if _, err := hs.InviteMember(household, bob.UserID.String(), app.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hs.AcceptInvite(household, bob.UserID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := hs.RemoveMember(household, ann.UserID.String()); !errors.Is(err, app.ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner removing the only owner, got %v", err)
+	}
+	if err := hs.RemoveMember(household, bob.UserID.String()); err != nil {
+		t.Fatal(err)
+	}
+	member, err := hs.Membership(bob.UserID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.HouseholdID == ann.HouseholdID || member.Role != app.RoleOwner {
+		t.Errorf("Expected bob to own a new household, got %+v", member)
+	}
+	if recipes, err := rs.HouseholdRecipes(household); err != nil || len(recipes) != 1 {
+		t.Errorf("Expected the recipe to stay with the household of ann, got %d, %v", len(recipes), err)
+	}
+}
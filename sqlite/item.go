@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// Items returns the ingredient catalogue.
+func (r *RecipeService) Items() ([]*app.Item, error) {
+	rows, err := r.db.Query(`SELECT
+		id, name, default_unit, price, section, allergens
+	FROM item
+	ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*app.Item, 0)
+	for rows.Next() {
+		var item app.Item
+		var allergens string
+		if err := rows.Scan(&item.ID, &item.Name, &item.Unit, &item.Price, &item.Section, &allergens); err != nil {
+			return nil, err
+		}
+		item.Allergens = splitAllergens(allergens)
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+// saveRecipeItems replaces the ingredients of a recipe. Ingredients that are
+// not in the catalogue are added to it, with the unit, price and section
+// they are given here as defaults.
+func saveRecipeItems(tx *sql.Tx, recipeID string, items []*app.Item) error {
+	if _, err := tx.Exec("DELETE FROM recipes_items WHERE recipe_id = ?", recipeID); err != nil {
+		return err
+	}
+	for i, item := range items {
+		catalogued, err := catalogueItem(tx, item)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO recipes_items(
+			recipe_id, item_id, amount, unit, price, position
+		)
+		VALUES(?, ?, ?, ?, ?, ?)
+		`, recipeID, catalogued.ID.String(), item.Amount, item.Unit, item.Price, i)
+		if err != nil {
+			return err
+		}
+
+		item.ID = catalogued.ID
+		item.Name = catalogued.Name
+		if item.Unit == "" {
+			item.Unit = catalogued.Unit
+		}
+		if item.Price == 0 {
+			item.Price = catalogued.Price
+		}
+		if item.Section == "" {
+			item.Section = catalogued.Section
+		}
+		item.Allergens = catalogued.Allergens
+	}
+	return nil
+}
+
+// catalogueItem returns the catalogue entry with the name of item, adding
+// item to the catalogue if there is none. Allergens given with item replace
+// those in the catalogue.
+func catalogueItem(tx *sql.Tx, item *app.Item) (*app.Item, error) {
+	name := strings.TrimSpace(item.Name)
+	_, err := tx.Exec(`INSERT INTO item(
+		id, name, default_unit, price, section, allergens
+	)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		allergens = excluded.allergens
+	WHERE excluded.allergens != ''
+	`, uuid.New().String(), name, item.Unit, item.Price, item.Section, joinAllergens(item.Allergens))
+	if err != nil {
+		return nil, err
+	}
+	var catalogued app.Item
+	var allergens string
+	err = tx.QueryRow(`SELECT
+		id, name, default_unit, price, section, allergens
+	FROM item
+	WHERE name = ?`, name).Scan(&catalogued.ID, &catalogued.Name, &catalogued.Unit, &catalogued.Price, &catalogued.Section, &allergens)
+	if err != nil {
+		return nil, err
+	}
+	catalogued.Allergens = splitAllergens(allergens)
+	return &catalogued, nil
+}
+
+// recipeItems returns the ingredients of the recipes by recipe id. Units,
+// prices and sections left out in a recipe are taken from the catalogue.
+func (r *RecipeService) recipeItems(householdID string) (map[uuid.UUID][]*app.Item, error) {
+	rows, err := r.db.Query(`SELECT
+		ri.recipe_id, i.id, i.name, ri.amount,
+		CASE WHEN ri.unit = '' THEN i.default_unit ELSE ri.unit END,
+		CASE WHEN ri.price = 0 THEN i.price ELSE ri.price END,
+		i.section, i.allergens
+	FROM recipes_items ri
+	JOIN item i ON i.id = ri.item_id
+	WHERE ri.recipe_id IN (SELECT id FROM recipe WHERE `+ownedBy+`)
+	ORDER BY ri.recipe_id, ri.position
+	`, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := map[uuid.UUID][]*app.Item{}
+	for rows.Next() {
+		var recipeID uuid.UUID
+		var item app.Item
+		var allergens string
+		if err := rows.Scan(&recipeID, &item.ID, &item.Name, &item.Amount, &item.Unit, &item.Price, &item.Section, &allergens); err != nil {
+			return nil, err
+		}
+		item.Allergens = splitAllergens(allergens)
+		items[recipeID] = append(items[recipeID], &item)
+	}
+	return items, rows.Err()
+}
+
+func joinAllergens(allergens []app.Allergen) string {
+	s := make([]string, len(allergens))
+	for i, allergen := range allergens {
+		s[i] = string(allergen)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitAllergens(s string) []app.Allergen {
+	if s == "" {
+		return nil
+	}
+	allergens := []app.Allergen{}
+	for _, allergen := range strings.Split(s, ",") {
+		allergens = append(allergens, app.Allergen(allergen))
+	}
+	return allergens
+}
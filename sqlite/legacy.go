@@ -0,0 +1,293 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// Databases from before migrations had their tables created and extended
+// at startup. Upgrading one is part of applying the first migration: the
+// columns added over time are added to the tables it has, the migration
+// creates the tables it lacks, and then the data is moved to where it is
+// kept now.
+
+// legacyColumns are the columns added to tables after they were first
+// created.
+var legacyColumns = []struct{ table, column, definition string }{
+	{"user", "role", "TEXT NOT NULL DEFAULT 'user'"},
+	{"planner_settings", "household_portions", "INTEGER NOT NULL DEFAULT 0"},
+	{"planner_settings", "meal_slots", "TEXT NOT NULL DEFAULT 'dinner'"},
+	{"planner_settings", "weekly_budget", "REAL NOT NULL DEFAULT 0"},
+	{"recipe", "slots", "TEXT NOT NULL DEFAULT ''"},
+	{"recipe", "macros", "TEXT"},
+	{"recipe", "household_id", "TEXT NOT NULL DEFAULT ''"},
+	{"item", "allergens", "TEXT NOT NULL DEFAULT ''"},
+	{"recipes_items", "amount", "REAL NOT NULL DEFAULT 0"},
+	{"recipes_items", "unit", "TEXT NOT NULL DEFAULT ''"},
+	{"recipes_items", "price", "INTEGER NOT NULL DEFAULT 0"},
+	{"recipes_items", "position", "INTEGER NOT NULL DEFAULT 0"},
+	{"week", "seed", "INTEGER NOT NULL DEFAULT 0"},
+	{"week", "household_id", "TEXT NOT NULL DEFAULT ''"},
+	{"week", "status", "TEXT NOT NULL DEFAULT 'accepted'"},
+	{"week", "name", "TEXT NOT NULL DEFAULT ''"},
+	{"week", "updated_at", "DATETIME"},
+	{"pantry", "household_id", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// isLegacy reports whether the database was created before migrations,
+// which is when it has tables but none of the migrations are applied.
+func isLegacy(tx *sql.Tx) (bool, error) {
+	if ok, err := hasLegacyTables(tx); err != nil || !ok {
+		return false, err
+	}
+	var applied int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		return false, err
+	}
+	return applied == 0, nil
+}
+
+// hasLegacyTables reports whether the database has any of the tables of
+// the first version.
+func hasLegacyTables(db queryer) (bool, error) {
+	var tables int
+	err := db.QueryRow(`SELECT COUNT(*)
+	FROM sqlite_master
+	WHERE type = 'table' AND name IN ('user', 'recipe', 'week')`).Scan(&tables)
+	return tables > 0, err
+}
+
+// addLegacyColumns adds the columns missing from the tables of a legacy
+// database.
+func addLegacyColumns(tx *sql.Tx) error {
+	for _, c := range legacyColumns {
+		var exists int
+		err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", c.table).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			continue
+		}
+		if err := addColumn(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyData moves the data of a legacy database to the tables and
+// columns it is kept in now.
+func migrateLegacyData(tx *sql.Tx) error {
+	if ok, err := hasColumn(tx, "user", "token"); err != nil {
+		return err
+	} else if ok {
+		if err := migrateUserTokens(tx); err != nil {
+			return err
+		}
+	}
+	if err := migrateUsers(tx); err != nil {
+		return err
+	}
+	if ok, err := hasColumn(tx, "week", "days"); err != nil {
+		return err
+	} else if ok {
+		if err := migrateDrafts(tx); err != nil {
+			return err
+		}
+		if err := migrateWeekDays(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUserTokens turns the tokens from when a user had a single one
+// into sessions, so that nobody is logged out by the upgrade.
+func migrateUserTokens(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, token FROM user WHERE token IS NOT NULL AND token != ''")
+	if err != nil {
+		return err
+	}
+	sessions := []*app.Session{}
+	tokens := []string{}
+	now := time.Now().UTC()
+	for rows.Next() {
+		var userID uuid.UUID
+		var token string
+		if err := rows.Scan(&userID, &token); err != nil {
+			rows.Close()
+			return err
+		}
+		sessions = append(sessions, app.NewSession(userID, "", now))
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i, session := range sessions {
+		if err := insertSession(tx, session, tokens[i]); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE user SET token = NULL")
+	return err
+}
+
+// migrateUsers moves users from before households existed into a household
+// of their own, together with their recipes, weeks and pantry.
+func migrateUsers(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, name
+	FROM user
+	WHERE id NOT IN (SELECT user_id FROM household_member)`)
+	if err != nil {
+		return err
+	}
+	type user struct{ id, name string }
+	users := []user{}
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.id, &u.name); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, u := range users {
+		if _, err := createHousehold(tx, u.name, u.id); err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"recipe", "week", "pantry"} {
+		_, err := tx.Exec(`UPDATE ` + table + `
+		SET household_id = (SELECT household_id FROM household_member m WHERE m.user_id = ` + table + `.user_id)
+		WHERE household_id = '' AND user_id IN (SELECT user_id FROM household_member)`)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDrafts turns the drafts from when they were stored as week -1
+// into drafts numbered by the first week they plan.
+func migrateDrafts(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, days FROM week WHERE number = -1")
+	if err != nil {
+		return err
+	}
+	numbers := map[string][2]int{}
+	for rows.Next() {
+		var id string
+		var daysJSON sql.NullString
+		if err := rows.Scan(&id, &daysJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var days []*app.Day
+		if daysJSON.String != "" {
+			if err := json.Unmarshal([]byte(daysJSON.String), &days); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		year, number := time.Now().ISOWeek()
+		if len(days) > 0 {
+			year, number = days[0].Date.ISOWeek()
+		}
+		numbers[id] = [2]int{year, number}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, yearNumber := range numbers {
+		_, err := tx.Exec("UPDATE week SET status = ?, year = ?, number = ? WHERE id = ?",
+			app.WeekDraft, yearNumber[0], yearNumber[1], id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateWeekDays moves the days of weeks stored as JSON in week.days to
+// the day and meal tables. Meals of recipes that no longer exist are
+// dropped and logged, their days are kept.
+func migrateWeekDays(tx *sql.Tx) error {
+	recipes := map[uuid.UUID]bool{}
+	rows, err := tx.Query("SELECT id FROM recipe")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		recipes[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query("SELECT id, days FROM week WHERE days IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	weeks := map[uuid.UUID][]*app.Day{}
+	for rows.Next() {
+		var id uuid.UUID
+		var daysJSON string
+		if err := rows.Scan(&id, &daysJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var days []*app.Day
+		if daysJSON != "" {
+			if err := json.Unmarshal([]byte(daysJSON), &days); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read days of week %s: %w", id, err)
+			}
+		}
+		weeks[id] = days
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, days := range weeks {
+		for _, day := range days {
+			for slot, meal := range day.Meals {
+				if meal.Recipe == nil {
+					delete(day.Meals, slot)
+				} else if !recipes[meal.Recipe.ID] {
+					log.Printf("dropping the %s of week %s on %s, recipe %s (%s) no longer exists",
+						slot, id, day.Date.Format(dateFormat), meal.Recipe.ID, meal.Recipe.Name)
+					delete(day.Meals, slot)
+				}
+			}
+		}
+		if err := insertDays(tx, id, days); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE week SET days = NULL WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// legacyVersion is the migration whose schema databases from before
+// migrations are upgraded to, see isLegacy.
+const legacyVersion = 1
+
+// Migration is a change to the schema, read from the files
+// migrations/<version>_<name>.up.sql and .down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	// AppliedAt is nil for migrations not applied to the database.
+	AppliedAt *time.Time
+	up, down  string
+}
+
+// migrations returns the migrations in the order they are applied.
+func migrations() ([]*Migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := []*Migration{}
+	for _, file := range files {
+		base := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".up.sql")
+		version, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.up.sql", file)
+		}
+		m := &Migration{Name: name}
+		if m.Version, err = strconv.Atoi(version); err != nil {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.up.sql", file)
+		}
+		up, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		down, err := migrationFiles.ReadFile(strings.TrimSuffix(file, ".up.sql") + ".down.sql")
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no down migration: %w", file, err)
+		}
+		m.up, m.down = string(up), string(down)
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func createMigrationTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`)
+	return err
+}
+
+// MigrationStatus returns every migration, with when it was applied to the
+// database.
+func MigrationStatus(db *sql.DB) ([]*Migration, error) {
+	migrations, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := hasMigrationTable(db); err != nil || !ok {
+		return migrations, err
+	}
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, m := range migrations {
+		if appliedAt, ok := applied[m.Version]; ok {
+			m.AppliedAt = &appliedAt
+		}
+	}
+	return migrations, nil
+}
+
+func hasMigrationTable(db *sql.DB) (bool, error) {
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&exists)
+	return exists > 0, err
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database, 0 if none is. It can be newer than any migration known here
+// when the database was migrated by a later version.
+func SchemaVersion(db *sql.DB) (int, error) {
+	if ok, err := hasMigrationTable(db); err != nil || !ok {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	return int(version.Int64), err
+}
+
+// MigrateUp applies the migrations not yet applied, in order, each in a
+// transaction of its own. Returns the migrations applied.
+func MigrateUp(db *sql.DB) ([]*Migration, error) {
+	if err := createMigrationTable(db); err != nil {
+		return nil, err
+	}
+	migrations, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	applied := []*Migration{}
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			continue
+		}
+		if err := migrateUp(db, m); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func migrateUp(db *sql.DB, m *Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	legacy := false
+	if m.Version == legacyVersion {
+		if legacy, err = isLegacy(tx); err != nil {
+			return err
+		}
+	}
+	if legacy {
+		if err := addLegacyColumns(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(m.up); err != nil {
+		return err
+	}
+	if legacy {
+		if err := migrateLegacyData(tx); err != nil {
+			return err
+		}
+	}
+	now := time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, now)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.AppliedAt = &now
+	return nil
+}
+
+// MigrateDown reverts the last migration applied, returning it, or nil if
+// no migration is applied.
+func MigrateDown(db *sql.DB) (*Migration, error) {
+	if err := createMigrationTable(db); err != nil {
+		return nil, err
+	}
+	migrations, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	var last *Migration
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			last = m
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(last.down); err != nil {
+		return nil, fmt.Errorf("failed to revert migration %04d_%s: %w", last.Version, last.Name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", last.Version); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	last.AppliedAt = nil
+	return last, nil
+}
@@ -0,0 +1,262 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "mealshuffler.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func latestVersion(t *testing.T) int {
+	t.Helper()
+	migrations, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) == 0 || applied[len(applied)-1].Version != latestVersion(t) {
+		t.Fatalf("Expected every migration to be applied, got %d", len(applied))
+	}
+	if applied, err := MigrateUp(db); err != nil || len(applied) != 0 {
+		t.Fatalf("Expected nothing left to apply, got %d, %v", len(applied), err)
+	}
+
+	users := NewUserService(db)
+	user, err := users.CreateUser(&app.NewUser{Name: "Ann", Username: "ann"}, []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := NewHouseholdService(db).Membership(user.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipe, err := NewRecipeService(db).CreateRecipe(&app.NewRecipe{Name: "Soup", Portions: 4, ProbabilityWeight: 1}, member.HouseholdID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := &app.Day{}
+	day.SetRecipe(app.MealDinner, recipe)
+	weeks := NewWeekService(db)
+	week, err := weeks.CreateWeek(&app.NewWeek{Number: 1, Year: 2024, Days: []*app.Day{day}}, member.HouseholdID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := weeks.Week(week.ID.String(), member.HouseholdID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Days) != 1 || stored.Days[0].Recipe(app.MealDinner).Name != "Soup" {
+		t.Errorf("Expected the week to be stored with its day, got %+v", stored.Days)
+	}
+
+	for version := latestVersion(t); version > 0; version-- {
+		reverted, err := MigrateDown(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverted == nil || reverted.Version != version {
+			t.Fatalf("Expected migration %d to be reverted, got %+v", version, reverted)
+		}
+	}
+	if version, err := SchemaVersion(db); err != nil || version != 0 {
+		t.Errorf("Expected no migration to be applied, got %d, %v", version, err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("Expected every table to be dropped, %d are left", tables)
+	}
+}
+
+// legacySchema is the schema of the first version, before migrations.
+const legacySchema = `
+CREATE TABLE recipe (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	probability_weight REAL NOT NULL,
+	portions INTEGER NOT NULL,
+	left_over_compliance INTEGER NOT NULL,
+	url TEXT,
+	user_id TEXT
+);
+CREATE TABLE recipes_items (
+	recipe_id TEXT,
+	item_id TEXT
+);
+CREATE TABLE user (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	username TEXT UNIQUE NOT NULL,
+	token TEXT,
+	hash TEXT
+);
+CREATE TABLE week (
+	id TEXT PRIMARY KEY,
+	days TEXT,
+	number INTEGER NOT NULL,
+	year INTEGER NOT NULL,
+	user_id TEXT NOT NULL
+);
+INSERT INTO user (id, name, username, token, hash)
+VALUES ('6b0c5a4e-2a63-4a8e-9d47-3a3f4f1f0c01', 'Ann', 'ann', 'secret', 'hash');
+INSERT INTO recipe (id, name, probability_weight, portions, left_over_compliance, url, user_id)
+VALUES ('1f6e7d4c-6d2b-4f0e-8a51-0c1b2a3d4e01', 'Soup', 1, 4, 0, '', '6b0c5a4e-2a63-4a8e-9d47-3a3f4f1f0c01');
+INSERT INTO week (id, days, number, year, user_id)
+VALUES ('9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c01', '[
+	{"id": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c501", "date": "2024-01-01T00:00:00Z", "dinner": {"id": "1f6e7d4c-6d2b-4f0e-8a51-0c1b2a3d4e01", "name": "Soup"}},
+	{"id": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c502", "date": "2024-01-02T00:00:00Z", "dinner": {"id": "7e7e7e7e-7e7e-4e7e-8e7e-7e7e7e7e7e7e", "name": "Gone"}}
+]', 1, 2024, '6b0c5a4e-2a63-4a8e-9d47-3a3f4f1f0c01'),
+('9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c02', '[
+	{"id": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c503", "date": "2024-01-08T00:00:00Z", "dinner": {"id": "1f6e7d4c-6d2b-4f0e-8a51-0c1b2a3d4e01", "name": "Soup"}}
+]', -1, 2024, '6b0c5a4e-2a63-4a8e-9d47-3a3f4f1f0c01');
+`
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := SchemaVersion(db); err != nil || version != latestVersion(t) {
+		t.Fatalf("Expected the latest schema version, got %d, %v", version, err)
+	}
+
+	users := NewUserService(db)
+	session, err := users.ValidateUserToken("secret")
+	if err != nil {
+		t.Fatalf("Expected the token of the user to become a session: %v", err)
+	}
+	member, err := NewHouseholdService(db).Membership(session.UserID.String())
+	if err != nil {
+		t.Fatalf("Expected the user to get a household: %v", err)
+	}
+	householdID := member.HouseholdID.String()
+
+	recipes, err := NewRecipeService(db).HouseholdRecipes(householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipes) != 1 || recipes[0].Name != "Soup" {
+		t.Errorf("Expected the recipe of the user in their household, got %+v", recipes)
+	}
+
+	weeks := NewWeekService(db)
+	accepted, err := weeks.Weeks(householdID, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accepted) != 1 {
+		t.Fatalf("Expected one accepted week, got %d", len(accepted))
+	}
+	if days := accepted[0].Days; len(days) != 2 || days[0].Recipe(app.MealDinner).Name != "Soup" || days[1].Recipe(app.MealDinner) != nil {
+		t.Errorf("Expected the days to move with the meals of existing recipes only, got %+v", days)
+	}
+	draft, err := weeks.LastGeneratedWeek(householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.Number != 2 || len(draft.Days) != 1 {
+		t.Errorf("Expected week -1 to become a draft of week 2, got week %d with %d days", draft.Number, len(draft.Days))
+	}
+
+	if _, err := weeks.CreateWeek(&app.NewWeek{Number: 3, Year: 2024}, householdID); err != nil {
+		t.Errorf("Expected weeks to be created in the upgraded table: %v", err)
+	}
+}
+
+func TestMigrateWeekDays(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	recipe, err := rs.CreateRecipe(&app.NewRecipe{Name: "Soup", Portions: 4, ProbabilityWeight: 1}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.db.Exec("ALTER TABLE week ADD COLUMN days TEXT"); err != nil {
+		t.Fatal(err)
+	}
+	weekID := uuid.New()
+	days := `[
+		{"id": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c501", "date": "2024-01-01T00:00:00Z", "meals": {
+			"lunch": {"recipe": {"id": "7e7e7e7e-7e7e-4e7e-8e7e-7e7e7e7e7e7e", "name": "Gone"}},
+			"dinner": {"recipe": {"id": "` + recipe.ID.String() + `", "name": "Soup"}}
+		}},
+		{"id": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c502", "date": "2024-01-02T00:00:00Z", "dinner": {"id": "` + recipe.ID.String() + `", "name": "Soup"}, "leftover_of": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c501"},
+		{"id": "0d1e2f3a-4b5c-4d6e-8f70-8192a3b4c503", "date": "2024-01-03T00:00:00Z", "dinner": {"id": "7e7e7e7e-7e7e-4e7e-8e7e-7e7e7e7e7e7e", "name": "Gone"}}
+	]`
+	_, err = rs.db.Exec("INSERT INTO week (id, number, year, household_id, days) VALUES (?, 1, 2024, ?, ?)", weekID, householdID, days)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	tx, err := rs.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := migrateWeekDays(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	week, err := NewWeekService(rs.db).Week(weekID.String(), householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(week.Days) != 3 {
+		t.Fatalf("Expected every day to be kept, got %d", len(week.Days))
+	}
+	first, second, third := week.Days[0], week.Days[1], week.Days[2]
+	if first.Recipe(app.MealDinner) == nil || first.Recipe(app.MealLunch) != nil {
+		t.Errorf("Expected only the dinner of the first day, got %v", first.Slots())
+	}
+	if meal := second.Meals[app.MealDinner]; meal == nil || !meal.IsLeftover() || *meal.LeftoverOf != first.ID {
+		t.Errorf("Expected the second dinner to be leftovers of the first, got %+v", meal)
+	}
+	if len(third.Meals) != 0 || third.Date.Format(dateFormat) != "2024-01-03" {
+		t.Errorf("Expected the third day without meals, got %+v", third)
+	}
+	for _, dropped := range []string{"lunch of week " + weekID.String() + " on 2024-01-01", "dinner of week " + weekID.String() + " on 2024-01-03"} {
+		if !strings.Contains(logged.String(), dropped) {
+			t.Errorf("Expected the dropped %s to be logged, got %q", dropped, logged.String())
+		}
+	}
+	var left int
+	if err := rs.db.QueryRow("SELECT COUNT(*) FROM week WHERE days IS NOT NULL").Scan(&left); err != nil || left != 0 {
+		t.Errorf("Expected the JSON days to be cleared, %d left, %v", left, err)
+	}
+}
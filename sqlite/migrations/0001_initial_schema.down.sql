@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS pantry;
+DROP TABLE IF EXISTS meal;
+DROP TABLE IF EXISTS day;
+DROP TABLE IF EXISTS week;
+DROP TABLE IF EXISTS food;
+DROP TABLE IF EXISTS recipe_tag;
+DROP TABLE IF EXISTS recipes_items;
+DROP TABLE IF EXISTS item;
+DROP TABLE IF EXISTS recipe;
+DROP TABLE IF EXISTS household_member;
+DROP TABLE IF EXISTS household;
+DROP TABLE IF EXISTS dietary_profile;
+DROP TABLE IF EXISTS planner_settings;
+DROP TABLE IF EXISTS api_token;
+DROP TABLE IF EXISTS session;
+DROP TABLE IF EXISTS user;
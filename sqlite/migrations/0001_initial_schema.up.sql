@@ -0,0 +1,164 @@
+-- The schema as of when migrations were introduced. Tables are created if
+-- they don't exist, so that databases from before then can be completed,
+-- see isLegacy, addLegacyColumns and migrateLegacyData in legacy.go.
+
+CREATE TABLE IF NOT EXISTS user (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	username TEXT UNIQUE NOT NULL,
+	hash TEXT,
+	role TEXT NOT NULL DEFAULT 'user'
+);
+
+CREATE TABLE IF NOT EXISTS session (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	label TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	last_seen_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_session_user ON session (user_id);
+
+CREATE TABLE IF NOT EXISTS api_token (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	last_used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_api_token_user ON api_token (user_id);
+
+CREATE TABLE IF NOT EXISTS planner_settings (
+	user_id TEXT PRIMARY KEY,
+	decay_curve TEXT NOT NULL,
+	decay_horizon_days INTEGER NOT NULL,
+	household_portions INTEGER NOT NULL DEFAULT 0,
+	meal_slots TEXT NOT NULL DEFAULT 'dinner',
+	weekly_budget REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS dietary_profile (
+	user_id TEXT PRIMARY KEY,
+	vegetarian INTEGER NOT NULL DEFAULT 0,
+	gluten_free INTEGER NOT NULL DEFAULT 0,
+	lactose_free INTEGER NOT NULL DEFAULT 0,
+	allergens TEXT NOT NULL DEFAULT '',
+	excluded_ingredients TEXT NOT NULL DEFAULT '[]',
+	excluded_tags TEXT NOT NULL DEFAULT '[]'
+);
+
+CREATE TABLE IF NOT EXISTS household (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS household_member (
+	user_id TEXT PRIMARY KEY,
+	household_id TEXT NOT NULL,
+	role TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_household_member ON household_member (household_id);
+
+-- user_id in recipe, week and pantry is the owner from before households.
+-- Recipes without a household or user are shared by everyone.
+CREATE TABLE IF NOT EXISTS recipe (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	probability_weight REAL NOT NULL,
+	portions INTEGER NOT NULL,
+	left_over_compliance INTEGER NOT NULL,
+	url TEXT,
+	user_id TEXT,
+	slots TEXT NOT NULL DEFAULT '',
+	macros TEXT,
+	household_id TEXT NOT NULL DEFAULT ''
+);
+
+-- item is the ingredient catalogue shared by recipes and the pantry.
+CREATE TABLE IF NOT EXISTS item (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	default_unit TEXT NOT NULL DEFAULT '',
+	price INTEGER NOT NULL DEFAULT 0,
+	section TEXT NOT NULL DEFAULT '',
+	allergens TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS recipes_items (
+	recipe_id TEXT,
+	item_id TEXT,
+	amount REAL NOT NULL DEFAULT 0,
+	unit TEXT NOT NULL DEFAULT '',
+	price INTEGER NOT NULL DEFAULT 0,
+	position INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_recipe_item ON recipes_items (recipe_id, item_id);
+CREATE INDEX IF NOT EXISTS idx_item ON recipes_items (item_id);
+
+CREATE TABLE IF NOT EXISTS recipe_tag (
+	recipe_id TEXT NOT NULL,
+	type TEXT NOT NULL DEFAULT '',
+	value TEXT NOT NULL,
+	PRIMARY KEY (recipe_id, type, value)
+);
+CREATE INDEX IF NOT EXISTS idx_tag ON recipe_tag (type, value);
+
+-- food holds the nutrients in 100 g of each food.
+CREATE TABLE IF NOT EXISTS food (
+	name TEXT PRIMARY KEY COLLATE NOCASE,
+	kcal REAL NOT NULL DEFAULT 0,
+	protein REAL NOT NULL DEFAULT 0,
+	fat REAL NOT NULL DEFAULT 0,
+	carbs REAL NOT NULL DEFAULT 0,
+	fibre REAL NOT NULL DEFAULT 0,
+	salt REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS week (
+	id TEXT PRIMARY KEY,
+	number INTEGER NOT NULL,
+	year INTEGER NOT NULL,
+	user_id TEXT NOT NULL DEFAULT '',
+	seed INTEGER NOT NULL DEFAULT 0,
+	household_id TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'accepted',
+	name TEXT NOT NULL DEFAULT '',
+	updated_at DATETIME
+);
+
+-- A day is identified by its id within its week, since a draft and the
+-- weeks it is accepted as share days.
+CREATE TABLE IF NOT EXISTS day (
+	id TEXT NOT NULL,
+	week_id TEXT NOT NULL REFERENCES week(id) ON DELETE CASCADE,
+	date DATE NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (week_id, id)
+);
+CREATE INDEX IF NOT EXISTS idx_day_date ON day (date);
+
+CREATE TABLE IF NOT EXISTS meal (
+	week_id TEXT NOT NULL,
+	day_id TEXT NOT NULL,
+	slot TEXT NOT NULL,
+	recipe_id TEXT NOT NULL REFERENCES recipe(id) ON DELETE CASCADE,
+	leftover_of TEXT,
+	PRIMARY KEY (week_id, day_id, slot),
+	FOREIGN KEY (week_id, day_id) REFERENCES day(week_id, id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_meal_recipe ON meal (recipe_id);
+
+CREATE TABLE IF NOT EXISTS pantry (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL DEFAULT '',
+	household_id TEXT NOT NULL DEFAULT '',
+	item_id TEXT NOT NULL,
+	amount REAL NOT NULL DEFAULT 0,
+	unit TEXT NOT NULL DEFAULT '',
+	best_before DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_pantry_household ON pantry (household_id);
@@ -0,0 +1,9 @@
+-- Users join a household by accepting an invite from one of its owners.
+CREATE TABLE household_invite (
+	household_id TEXT NOT NULL REFERENCES household(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (household_id, user_id)
+);
+CREATE INDEX idx_household_invite_user ON household_invite (user_id);
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+type NutrientService struct {
+	db *sql.DB
+}
+
+func NewNutrientService(db *sql.DB) *NutrientService {
+	return &NutrientService{db: db}
+}
+
+func (ns *NutrientService) Foods() ([]*app.Food, error) {
+	rows, err := ns.db.Query(`SELECT
+		name, kcal, protein, fat, carbs, fibre, salt
+	FROM food
+	ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	foods := make([]*app.Food, 0)
+	for rows.Next() {
+		var food app.Food
+		n := &food.Per100g
+		if err := rows.Scan(&food.Name, &n.Kcal, &n.Protein, &n.Fat, &n.Carbs, &n.Fibre, &n.Salt); err != nil {
+			return nil, err
+		}
+		foods = append(foods, &food)
+	}
+	return foods, rows.Err()
+}
+
+func (ns *NutrientService) ImportFoods(foods []*app.Food) (int, error) {
+	tx, err := ns.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO food(
+		name, kcal, protein, fat, carbs, fibre, salt
+	)
+	VALUES(?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		kcal = excluded.kcal,
+		protein = excluded.protein,
+		fat = excluded.fat,
+		carbs = excluded.carbs,
+		fibre = excluded.fibre,
+		salt = excluded.salt
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, food := range foods {
+		n := food.Per100g
+		if _, err := stmt.Exec(food.Name, n.Kcal, n.Protein, n.Fat, n.Carbs, n.Fibre, n.Salt); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(foods), nil
+}
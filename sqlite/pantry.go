@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+type PantryService struct {
+	db *sql.DB
+}
+
+func NewPantryService(db *sql.DB) *PantryService {
+	return &PantryService{db: db}
+}
+
+// PantryItems returns what the user has at home, the items closest to
+// their best before date first.
+func (ps *PantryService) PantryItems(householdID string) ([]*app.PantryItem, error) {
+	rows, err := ps.db.Query(`SELECT
+		p.id, i.name, p.amount, p.unit, p.best_before
+	FROM pantry p
+	JOIN item i ON i.id = p.item_id
+	WHERE p.household_id = ?
+	ORDER BY p.best_before IS NULL, p.best_before, i.name
+	`, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*app.PantryItem, 0)
+	for rows.Next() {
+		var item app.PantryItem
+		var bestBefore sql.NullTime
+		if err := rows.Scan(&item.ID, &item.Name, &item.Amount, &item.Unit, &bestBefore); err != nil {
+			return nil, err
+		}
+		if bestBefore.Valid {
+			item.BestBefore = &bestBefore.Time
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func (ps *PantryService) CreatePantryItem(newItem *app.NewPantryItem, householdID string) (*app.PantryItem, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	catalogued, err := catalogueItem(tx, &app.Item{Name: newItem.Name, Unit: newItem.Unit})
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.New()
+	_, err = tx.Exec(`INSERT INTO pantry(
+		id, household_id, item_id, amount, unit, best_before
+	)
+	VALUES(?, ?, ?, ?, ?, ?)
+	`, id.String(), householdID, catalogued.ID.String(), newItem.Amount, newItem.Unit, newItem.BestBefore)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	item := &app.PantryItem{
+		NewPantryItem: *newItem,
+		Entity: app.Entity{
+			ID: id,
+		},
+	}
+	item.Name = catalogued.Name
+	return item, nil
+}
+
+// UpdatePantryItem replaces the pantry item with the id of item, returning
+// sql.ErrNoRows if the user has no such item.
+func (ps *PantryService) UpdatePantryItem(item *app.PantryItem, householdID string) (*app.PantryItem, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	catalogued, err := catalogueItem(tx, &app.Item{Name: item.Name, Unit: item.Unit})
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec(`UPDATE pantry
+	SET item_id = ?, amount = ?, unit = ?, best_before = ?
+	WHERE id = ? AND household_id = ?
+	`, catalogued.ID.String(), item.Amount, item.Unit, item.BestBefore, item.ID.String(), householdID)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	item.Name = catalogued.Name
+	return item, nil
+}
+
+// DeletePantryItem removes an item from the pantry, returning sql.ErrNoRows
+// if the user has no such item.
+func (ps *PantryService) DeletePantryItem(id string, householdID string) error {
+	result, err := ps.db.Exec("DELETE FROM pantry WHERE id = ? AND household_id = ?", id, householdID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
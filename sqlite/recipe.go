@@ -2,6 +2,8 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/json"
+	"strings"
 
 	"github.com/google/uuid"
 
@@ -13,62 +15,39 @@ type RecipeService struct {
 }
 
 func NewRecipeService(db *sql.DB) *RecipeService {
-	rs := &RecipeService{db: db}
-	err := rs.CreateRecipeTable()
-	if err != nil {
-		panic(err)
-	}
-	return rs
-}
-
-func (r *RecipeService) CreateRecipeTable() error {
-	query := `CREATE TABLE IF NOT EXISTS recipe (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		probability_weight REAL NOT NULL,
-		portions INTEGER NOT NULL,
-		left_over_compliance INTEGER NOT NULL,
-		url TEXT,
-		user_id TEXT
-	);`
-	if _, err := r.db.Exec(query); err != nil {
-		return err
-	}
-
-	query = `CREATE TABLE IF NOT EXISTS recipes_items (
-		recipe_id TEXT,
-		item_id TEXT
-	);
-	CREATE INDEX IF NOT EXISTS idx_recipe_item ON recipes_items (recipe_id, item_id);
-	CREATE INDEX IF NOT EXISTS idx_item ON recipes_items (item_id);
-	`
-	if _, err := r.db.Exec(query); err != nil {
-		return err
-	}
-
-	return nil
+	return &RecipeService{db: db}
 }
 
-// Recipes returns all recipes that are not owned by a user
+// Recipes returns all recipes that are not owned by a household
 func (r *RecipeService) Recipes() ([]*app.Recipe, error) {
 	return r.getRecipes()
 }
 
-func (r *RecipeService) UserRecipes(userID string) ([]*app.Recipe, error) {
-	return r.getRecipes(userID)
+// HouseholdRecipes returns the recipes of the household and those not owned
+// by any household.
+func (r *RecipeService) HouseholdRecipes(householdID string) ([]*app.Recipe, error) {
+	return r.getRecipes(householdID)
 }
 
-func (r *RecipeService) getRecipes(userID ...string) ([]*app.Recipe, error) {
-	var uID string
-	if len(userID) > 0 {
-		uID = userID[0]
+// sharedRecipe is the condition for recipes shared by everyone.
+const sharedRecipe = `(household_id = '' AND (user_id IS NULL OR user_id = ''))`
+
+// ownedBy is the condition for recipes of the household given as parameter,
+// or shared by everyone.
+const ownedBy = `(household_id = ? OR ` + sharedRecipe + `)`
+
+func (r *RecipeService) getRecipes(householdID ...string) ([]*app.Recipe, error) {
+	var hID string
+	if len(householdID) > 0 {
+		hID = householdID[0]
 	}
 
 	rows, err := r.db.Query(`SELECT 
-		id, name, probability_weight, portions, left_over_compliance, url
+		id, name, probability_weight, portions, left_over_compliance, url, slots, macros
 	FROM recipe
-	WHERE user_id = ? or user_id is null or user_id = ''
-	`, uID)
+	WHERE `+ownedBy+`
+	ORDER BY name, id
+	`, hID)
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +58,9 @@ func (r *RecipeService) getRecipes(userID ...string) ([]*app.Recipe, error) {
 		var r app.Recipe
 		var leftOverCompliance sql.NullBool
 		var url sql.NullString
-		if err := rows.Scan(&r.ID, &r.Name, &r.ProbabilityWeight, &r.Portions, &leftOverCompliance, &url); err != nil {
+		var slots string
+		var macros sql.NullString
+		if err := rows.Scan(&r.ID, &r.Name, &r.ProbabilityWeight, &r.Portions, &leftOverCompliance, &url, &slots, &macros); err != nil {
 			return nil, err
 		}
 		if leftOverCompliance.Valid {
@@ -90,24 +71,46 @@ func (r *RecipeService) getRecipes(userID ...string) ([]*app.Recipe, error) {
 		if url.Valid {
 			r.URL = url.String
 		}
+		r.Slots = splitSlots(slots)
+		if r.Macros, err = splitMacros(macros); err != nil {
+			return nil, err
+		}
 		recipes = append(recipes, &r)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	items, err := r.recipeItems(hID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := r.recipeTags(hID)
+	if err != nil {
+		return nil, err
+	}
+	for _, recipe := range recipes {
+		recipe.Items = items[recipe.ID]
+		recipe.Tags = tags[recipe.ID]
+	}
 
 	return recipes, nil
 
 }
 
-func (r *RecipeService) CreateRecipe(newRecipe *app.NewRecipe, userID string) (*app.Recipe, error) {
+func (r *RecipeService) CreateRecipe(newRecipe *app.NewRecipe, householdID string) (*app.Recipe, error) {
 	id := uuid.New()
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 	stmt, err := tx.Prepare(`INSERT INTO 
 	recipe(
-		id, name, probability_weight, portions, left_over_compliance, url, user_id
+		id, name, probability_weight, portions, left_over_compliance, url, household_id, slots, macros
 	)
-	VALUES(?, ?, ?, ?, ?, ?, ?)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return nil, err
@@ -121,11 +124,19 @@ func (r *RecipeService) CreateRecipe(newRecipe *app.NewRecipe, userID string) (*
 		newRecipe.Portions,
 		newRecipe.LeftOverCompliance,
 		newRecipe.URL,
-		userID,
+		householdID,
+		joinSlots(newRecipe.Slots),
+		joinMacros(newRecipe.Macros),
 	)
 	if err != nil {
 		return nil, err
 	}
+	if err := saveRecipeItems(tx, id.String(), newRecipe.Items); err != nil {
+		return nil, err
+	}
+	if err := saveRecipeTags(tx, id.String(), newRecipe.Tags); err != nil {
+		return nil, err
+	}
 
 	recipe := &app.Recipe{
 		Entity: app.Entity{
@@ -136,9 +147,16 @@ func (r *RecipeService) CreateRecipe(newRecipe *app.NewRecipe, userID string) (*
 			Portions:           newRecipe.Portions,
 			ProbabilityWeight:  newRecipe.ProbabilityWeight,
 			LeftOverCompliance: newRecipe.LeftOverCompliance,
+			URL:                newRecipe.URL,
+			Slots:              newRecipe.Slots,
+			Items:              newRecipe.Items,
+			Tags:               newRecipe.Tags,
+			Macros:             newRecipe.Macros,
 		},
 	}
-	tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return recipe, nil
 }
@@ -156,6 +174,7 @@ func (r *RecipeService) DeleteRecipe(id int) error {
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 	stmt, err := tx.Prepare("DELETE FROM recepis WHERE id = ?")
 	if err != nil {
 		return err
@@ -165,8 +184,7 @@ func (r *RecipeService) DeleteRecipe(id int) error {
 	if _, err := stmt.Exec(id); err != nil {
 		return err
 	}
-	tx.Commit()
-	return nil
+	return tx.Commit()
 }
 
 func (rs *RecipeService) DeleteAllRecipes() error {
@@ -174,6 +192,7 @@ func (rs *RecipeService) DeleteAllRecipes() error {
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 	stmt, err := tx.Prepare("DELETE FROM recipe")
 	if err != nil {
 		return err
@@ -183,24 +202,101 @@ func (rs *RecipeService) DeleteAllRecipes() error {
 	if _, err := stmt.Exec(); err != nil {
 		return err
 	}
-	tx.Commit()
-	return nil
+	if _, err := tx.Exec("DELETE FROM recipes_items"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recipe_tag"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM meal"); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (rs *RecipeService) UpdateRecipe(recipe *app.Recipe) (*app.Recipe, error) {
+func (rs *RecipeService) UpdateRecipe(recipe *app.Recipe, householdID string) (*app.Recipe, error) {
 	tx, err := rs.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	stmt, err := tx.Prepare("UPDATE recipe SET name = ?, probability_weight = ?, portions = ? WHERE id = ?")
+	defer tx.Rollback()
+	// A household can only change its own recipes, the shared ones are
+	// changed without a household.
+	owner, args := "household_id = ?", []any{householdID}
+	if householdID == "" {
+		owner, args = sharedRecipe, nil
+	}
+	stmt, err := tx.Prepare("UPDATE recipe SET name = ?, probability_weight = ?, portions = ?, left_over_compliance = ?, url = ?, slots = ?, macros = ? WHERE id = ? AND " + owner)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	if _, err := stmt.Exec(recipe.Name, recipe.ProbabilityWeight, recipe.Portions, recipe.ID.String()); err != nil {
+	args = append([]any{recipe.Name, recipe.ProbabilityWeight, recipe.Portions, recipe.LeftOverCompliance, recipe.URL, joinSlots(recipe.Slots), joinMacros(recipe.Macros), recipe.ID.String()}, args...)
+	res, err := stmt.Exec(args...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	// Items and tags left out of the request are kept, an empty list
+	// removes them.
+	if recipe.Items != nil {
+		if err := saveRecipeItems(tx, recipe.ID.String(), recipe.Items); err != nil {
+			return nil, err
+		}
+	}
+	if recipe.Tags != nil {
+		if err := saveRecipeTags(tx, recipe.ID.String(), recipe.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	tx.Commit()
 	return recipe, nil
 }
+
+// joinSlots stores meal slots as a comma separated list.
+func joinSlots(slots []app.MealSlot) string {
+	s := make([]string, len(slots))
+	for i, slot := range slots {
+		s[i] = string(slot)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitSlots(s string) []app.MealSlot {
+	if s == "" {
+		return nil
+	}
+	slots := []app.MealSlot{}
+	for _, slot := range strings.Split(s, ",") {
+		slots = append(slots, app.MealSlot(slot))
+	}
+	return slots
+}
+
+// joinMacros stores the nutrients entered on a recipe as JSON, NULL when
+// there are none.
+func joinMacros(macros *app.Nutrients) sql.NullString {
+	if macros == nil {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(macros)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+func splitMacros(s sql.NullString) (*app.Nutrients, error) {
+	if !s.Valid || s.String == "" {
+		return nil, nil
+	}
+	var macros app.Nutrients
+	if err := json.Unmarshal([]byte(s.String), &macros); err != nil {
+		return nil, err
+	}
+	return &macros, nil
+}
@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// migratedDB returns a database with every migration applied and the id of
+// the household of a user in it.
+func migratedDB(t *testing.T) (*RecipeService, string) {
+	t.Helper()
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	user, err := NewUserService(db).CreateUser(&app.NewUser{Name: "Ann", Username: "ann"}, []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := NewHouseholdService(db).Membership(user.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRecipeService(db), member.HouseholdID.String()
+}
+
+func householdRecipe(t *testing.T, rs *RecipeService, householdID string) *app.Recipe {
+	t.Helper()
+	recipes, err := rs.HouseholdRecipes(householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipes) != 1 {
+		t.Fatalf("Expected one recipe, got %d", len(recipes))
+	}
+	return recipes[0]
+}
+
+func TestCreateRecipeStoresItemsAndTags(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	_, err := rs.CreateRecipe(&app.NewRecipe{
+		Name:              "Soup",
+		Portions:          4,
+		ProbabilityWeight: 1,
+		Items: []*app.Item{
+			{Name: "Leek", Amount: 2, Unit: "st", Price: 1500},
+			{Name: "Cream", Amount: 2, Unit: "dl", Allergens: []app.Allergen{app.AllergenLactose}},
+		},
+		Tags: []app.Tag{{Type: app.TagCuisine, Value: "french"}},
+	}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := householdRecipe(t, rs, householdID)
+	if len(stored.Items) != 2 || stored.Items[0].Name != "Leek" || stored.Items[0].Price != 1500 || stored.Items[1].Unit != "dl" {
+		t.Errorf("Expected the items in order with their amounts, got %+v", stored.Items)
+	}
+	if len(stored.Items) == 2 && (len(stored.Items[1].Allergens) != 1 || stored.Items[1].Allergens[0] != app.AllergenLactose) {
+		t.Errorf("Expected the allergens of the item to be catalogued, got %v", stored.Items[1].Allergens)
+	}
+	if len(stored.Tags) != 1 || stored.Tags[0].String() != "cuisine:french" {
+		t.Errorf("Expected the tags to be stored, got %v", stored.Tags)
+	}
+}
+
+func TestUpdateRecipeKeepsItemsAndTagsLeftOut(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	recipe, err := rs.CreateRecipe(&app.NewRecipe{
+		Name:              "Soup",
+		Portions:          4,
+		ProbabilityWeight: 1,
+		Items:             []*app.Item{{Name: "Leek", Amount: 2, Unit: "st"}},
+		Tags:              []app.Tag{{Type: app.TagCuisine, Value: "french"}},
+	}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := &app.Recipe{Entity: recipe.Entity, NewRecipe: app.NewRecipe{Name: "Leek soup", Portions: 4, ProbabilityWeight: 1}}
+	if _, err := rs.UpdateRecipe(renamed, householdID); err != nil {
+		t.Fatal(err)
+	}
+	stored := householdRecipe(t, rs, householdID)
+	if stored.Name != "Leek soup" || len(stored.Items) != 1 || len(stored.Tags) != 1 {
+		t.Errorf("Expected a rename to keep the items and tags, got %+v", stored)
+	}
+
+	cleared := &app.Recipe{Entity: recipe.Entity, NewRecipe: app.NewRecipe{
+		Name: "Leek soup", Portions: 4, ProbabilityWeight: 1, Items: []*app.Item{}, Tags: []app.Tag{},
+	}}
+	if _, err := rs.UpdateRecipe(cleared, householdID); err != nil {
+		t.Fatal(err)
+	}
+	stored = householdRecipe(t, rs, householdID)
+	if len(stored.Items) != 0 || len(stored.Tags) != 0 {
+		t.Errorf("Expected empty lists to remove the items and tags, got %+v and %v", stored.Items, stored.Tags)
+	}
+}
+
+func TestUpdateRecipeStoresURLAndLeftOverCompliance(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	recipe, err := rs.CreateRecipe(&app.NewRecipe{Name: "Soup", Portions: 4, ProbabilityWeight: 1}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated := &app.Recipe{Entity: recipe.Entity, NewRecipe: app.NewRecipe{
+		Name: "Soup", Portions: 4, ProbabilityWeight: 1, URL: "https://example.com/soup", LeftOverCompliance: true,
+	}}
+	if _, err := rs.UpdateRecipe(updated, householdID); err != nil {
+		t.Fatal(err)
+	}
+	stored := householdRecipe(t, rs, householdID)
+	if stored.URL != "https://example.com/soup" || !stored.LeftOverCompliance {
+		t.Errorf("Expected the url and left over compliance to be updated, got %q, %v", stored.URL, stored.LeftOverCompliance)
+	}
+
+	updated.URL, updated.LeftOverCompliance = "", false
+	if _, err := rs.UpdateRecipe(updated, householdID); err != nil {
+		t.Fatal(err)
+	}
+	stored = householdRecipe(t, rs, householdID)
+	if stored.URL != "" || stored.LeftOverCompliance {
+		t.Errorf("Expected the url and left over compliance to be cleared, got %q, %v", stored.URL, stored.LeftOverCompliance)
+	}
+}
+
+func TestUpdateRecipeOfAnotherHousehold(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	recipe, err := rs.CreateRecipe(&app.NewRecipe{
+		Name:              "Soup",
+		Portions:          4,
+		ProbabilityWeight: 1,
+		Items:             []*app.Item{{Name: "Leek", Amount: 2, Unit: "st"}},
+	}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := &app.Recipe{Entity: recipe.Entity, NewRecipe: app.NewRecipe{
+		Name: "Stolen", Portions: 4, ProbabilityWeight: 1, Items: []*app.Item{},
+	}}
+	if _, err := rs.UpdateRecipe(renamed, uuid.New().String()); err != sql.ErrNoRows {
+		t.Errorf("Expected a recipe of another household not to be found, got %v", err)
+	}
+	stored := householdRecipe(t, rs, householdID)
+	if stored.Name != "Soup" || len(stored.Items) != 1 {
+		t.Errorf("Expected the recipe to be left as it was, got %+v", stored)
+	}
+
+	renamed.ID = uuid.New()
+	if _, err := rs.UpdateRecipe(renamed, householdID); err != sql.ErrNoRows {
+		t.Errorf("Expected an unknown recipe not to be found, got %v", err)
+	}
+	var orphans int
+	if err := rs.db.QueryRow("SELECT COUNT(*) FROM recipes_items WHERE recipe_id = ?", renamed.ID).Scan(&orphans); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Errorf("Expected no items to be stored for an unknown recipe, got %d", orphans)
+	}
+}
+
+func TestUpdateSharedRecipe(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	shared, err := rs.CreateRecipe(&app.NewRecipe{
+		Name:              "Soup",
+		Portions:          4,
+		ProbabilityWeight: 1,
+		Tags:              []app.Tag{{Type: app.TagCuisine, Value: "french"}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := &app.Recipe{Entity: shared.Entity, NewRecipe: app.NewRecipe{
+		Name: "Stew", Portions: 4, ProbabilityWeight: 1, Tags: []app.Tag{},
+	}}
+	if _, err := rs.UpdateRecipe(renamed, householdID); err != sql.ErrNoRows {
+		t.Errorf("Expected a household not to change a shared recipe, got %v", err)
+	}
+	stored := householdRecipe(t, rs, householdID)
+	if stored.Name != "Soup" || len(stored.Tags) != 1 {
+		t.Errorf("Expected the shared recipe to be left as it was, got %+v", stored)
+	}
+
+	if _, err := rs.UpdateRecipe(renamed, ""); err != nil {
+		t.Fatal(err)
+	}
+	if stored := householdRecipe(t, rs, householdID); stored.Name != "Stew" || len(stored.Tags) != 0 {
+		t.Errorf("Expected the shared recipe to be changed without a household, got %+v", stored)
+	}
+}
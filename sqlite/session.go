@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// touchInterval is how often the last seen time of a session in use is
+// updated, to not write on every request.
+const touchInterval = time.Minute
+
+func insertSession(tx *sql.Tx, session *app.Session, token string) error {
+	_, err := tx.Exec(`INSERT INTO session(
+		id, user_id, token_hash, label, created_at, last_seen_at, expires_at
+	)
+	VALUES(?, ?, ?, ?, ?, ?, ?)
+	`, session.ID.String(), session.UserID.String(), app.HashToken(token), session.Label,
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt)
+	return err
+}
+
+// CreateSession starts a session of the user for token, clearing out
+// sessions that have expired.
+func (us *UserService) CreateSession(userID string, token string, label string) (*app.Session, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	session := app.NewSession(id, label, now)
+	tx, err := us.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM session WHERE expires_at <= ? OR last_seen_at <= ?", now, now.Add(-app.SessionIdleTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if err := insertSession(tx, session, token); err != nil {
+		return nil, err
+	}
+	return session, tx.Commit()
+}
+
+// ValidateUserToken returns the session of token, app.ErrSessionExpired if
+// it has expired.
+func (us *UserService) ValidateUserToken(token string) (*app.Session, error) {
+	var session app.Session
+	err := us.db.QueryRow(`SELECT
+		id, user_id, label, created_at, last_seen_at, expires_at
+	FROM session
+	WHERE token_hash = ?`, app.HashToken(token)).Scan(
+		&session.ID, &session.UserID, &session.Label, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if session.Expired(now) {
+		if _, err := us.db.Exec("DELETE FROM session WHERE id = ?", session.ID.String()); err != nil {
+			return nil, err
+		}
+		return nil, app.ErrSessionExpired
+	}
+	if now.Sub(session.LastSeenAt) > touchInterval {
+		if _, err := us.db.Exec("UPDATE session SET last_seen_at = ? WHERE id = ?", now, session.ID.String()); err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = now
+	}
+	return &session, nil
+}
+
+// Sessions returns the sessions of the user that have not expired, the
+// most recently used first.
+func (us *UserService) Sessions(userID string) ([]*app.Session, error) {
+	rows, err := us.db.Query(`SELECT
+		id, user_id, label, created_at, last_seen_at, expires_at
+	FROM session
+	WHERE user_id = ?
+	ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	now := time.Now().UTC()
+	sessions := []*app.Session{}
+	for rows.Next() {
+		var session app.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.Label, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if !session.Expired(now) {
+			sessions = append(sessions, &session)
+		}
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession revokes a session of the user, returning sql.ErrNoRows if
+// the user has no such session.
+func (us *UserService) DeleteSession(userID string, id string) error {
+	result, err := us.db.Exec("DELETE FROM session WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSessionByToken ends the session of token, returning sql.ErrNoRows
+// if there is none.
+func (us *UserService) DeleteSessionByToken(token string) error {
+	result, err := us.db.Exec("DELETE FROM session WHERE token_hash = ?", app.HashToken(token))
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+// saveRecipeTags replaces the tags of a recipe.
+func saveRecipeTags(tx *sql.Tx, recipeID string, tags []app.Tag) error {
+	if _, err := tx.Exec("DELETE FROM recipe_tag WHERE recipe_id = ?", recipeID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO recipe_tag(
+			recipe_id, type, value
+		)
+		VALUES(?, ?, ?)
+		`, recipeID, string(tag.Type), tag.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipeTags returns the tags of the recipes by recipe id.
+func (r *RecipeService) recipeTags(householdID string) (map[uuid.UUID][]app.Tag, error) {
+	rows, err := r.db.Query(`SELECT
+		t.recipe_id, t.type, t.value
+	FROM recipe_tag t
+	WHERE t.recipe_id IN (SELECT id FROM recipe WHERE `+ownedBy+`)
+	ORDER BY t.recipe_id, t.type, t.value
+	`, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[uuid.UUID][]app.Tag{}
+	for rows.Next() {
+		var recipeID uuid.UUID
+		var tag app.Tag
+		if err := rows.Scan(&recipeID, &tag.Type, &tag.Value); err != nil {
+			return nil, err
+		}
+		tags[recipeID] = append(tags[recipeID], tag)
+	}
+	return tags, rows.Err()
+}
@@ -3,7 +3,7 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/google/uuid"
 
@@ -15,28 +15,11 @@ type UserService struct {
 }
 
 func NewUserService(db *sql.DB) *UserService {
-	us := &UserService{db: db}
-	us.CreateUserTable()
-	return us
-}
-
-func (u *UserService) CreateUserTable() error {
-	query := `CREATE TABLE IF NOT EXISTS user (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		username TEXT UNIQUE NOT NULL,
-		token TEXT,
-		hash TEXT
-	);`
-	if _, err := u.db.Exec(query); err != nil {
-		return err
-	}
-
-	return nil
+	return &UserService{db: db}
 }
 
 func (u *UserService) Users() ([]*app.User, error) {
-	rows, err := u.db.Query("SELECT id, name FROM user")
+	rows, err := u.db.Query("SELECT id, name, role FROM user")
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +28,7 @@ func (u *UserService) Users() ([]*app.User, error) {
 	users := make([]*app.User, 0)
 	for rows.Next() {
 		var u app.User
-		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+		if err := rows.Scan(&u.ID, &u.Name, &u.Role); err != nil {
 			return nil, err
 		}
 		users = append(users, &u)
@@ -60,6 +43,7 @@ func (u *UserService) CreateUser(newUser *app.NewUser, hash []byte) (*app.User,
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 	stmt, err := tx.Prepare("INSERT INTO user(name, username, id, hash) VALUES(?,?,?,?)")
 	if err != nil {
 		return nil, err
@@ -70,6 +54,9 @@ func (u *UserService) CreateUser(newUser *app.NewUser, hash []byte) (*app.User,
 	if err != nil {
 		return nil, err
 	}
+	if _, err := createHousehold(tx, newUser.Name, id.String()); err != nil {
+		return nil, err
+	}
 
 	user := &app.User{
 		Entity: app.Entity{
@@ -78,16 +65,18 @@ func (u *UserService) CreateUser(newUser *app.NewUser, hash []byte) (*app.User,
 		NewUser: app.NewUser{
 			Name: newUser.Name,
 		},
+		Role: app.UserRoleUser,
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
-	tx.Commit()
-
 	return user, nil
 }
 
 func (u *UserService) User(id string) (*app.User, error) {
 	var idStr string
 	var user app.User
-	if err := u.db.QueryRow("SELECT id, name FROM user WHERE id = ?", id).Scan(&idStr, &user.Name); err != nil {
+	if err := u.db.QueryRow("SELECT id, name, username, role FROM user WHERE id = ?", id).Scan(&idStr, &user.Name, &user.Username, &user.Role); err != nil {
 		return nil, err
 	}
 	var err error
@@ -98,129 +87,130 @@ func (u *UserService) User(id string) (*app.User, error) {
 	return &user, nil
 }
 
+// DeleteUser deletes the user with their sessions, tokens and settings in
+// a single transaction. The recipes, weeks and pantry of their household
+// go with them when nobody else is left in it. Returns app.ErrLastOwner
+// when the household would be left without an owner.
 func (u *UserService) DeleteUser(id string) error {
 	tx, err := u.db.Begin()
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare("DELETE FROM user WHERE id = ?")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	if _, err := stmt.Exec(id); err != nil {
+	var householdID string
+	var role app.HouseholdRole
+	err = tx.QueryRow("SELECT household_id, role FROM household_member WHERE user_id = ?", id).Scan(&householdID, &role)
+	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
-	tx.Commit()
-	return nil
-}
-
-func (us *UserService) UserWeeks(id string, startWeek int, weekCount int) ([]*app.Week, error) {
-	endWeek := startWeek + weekCount - 1
-	if endWeek > 52 {
-		endWeek = 52
-	}
-	rows, err := us.db.Query(`SELECT 
-		w.id, w.number,
-		d.id, d.date, 
-		r.id, r.name, r.portions, r.probability_weight
-	FROM week as w 
-	INNER JOIN day as d ON d.week_id = w.id
-	INNER JOIN recipe as r ON r.id = d.dinner_id
-	WHERE 
-		user_id = ?
-		AND w.number >= ?
-		AND w.number <= ?`, id, startWeek, endWeek)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	weeks := make([]*app.Week, 0)
-	var days []*app.Day
-	lastWeek := -1
-	w := &app.Week{}
-	var wIDStr, dIDStr, rIDStr, weekDateStr string
-	// wID, wNumber, dID, dDate, rID, rName, rPortions, rProbabilityWeight
-	// -------------------------------------------------------------------
-	// 0,   1,       1,   8,      4,   5,     6,         7
-	// 0,   1,       2,   9,     10,   5,     6,         7
-	// 0,   1,       3,   10,    10,   5,     6,         7
-	// 0,   1,       4,   11,    10,   5,     6,         7
-	// 0,   1,       5,   12,    10,   5,     6,         7
-	// 0,   1,       6,   13,    10,   5,     6,         7
-	// 0,   1,       7,   14,    10,   5,     6,         7
-	// 1,   2,       8,   14,    10,   5,     6,         7
-	for rows.Next() {
-		d := &app.Day{}
-		r := &app.Recipe{}
-		if err := rows.Scan(&wIDStr, &w.Number,
-			&dIDStr, &weekDateStr,
-			&rIDStr, &r.Name, &r.Portions, &r.ProbabilityWeight); err != nil {
-			return nil, err
-		}
-		var err error
-		w.ID, err = uuid.Parse(wIDStr)
-		if err != nil {
-			return nil, err
-		}
-		d.ID, err = uuid.Parse(dIDStr)
+	if householdID != "" {
+		members, _, err := otherMembers(tx, householdID, id)
 		if err != nil {
-			return nil, err
-		}
-		r.ID, err = uuid.Parse(rIDStr)
-		if err != nil {
-			return nil, err
+			return err
 		}
-		if lastWeek == -1 {
-			lastWeek = w.Number
+		if members > 0 {
+			if err := checkOwnerLeft(tx, householdID, id); err != nil {
+				return err
+			}
+		} else if err := deleteHousehold(tx, householdID); err != nil {
+			return err
 		}
-		if lastWeek != w.Number {
-			w.Days = days
-			days = []*app.Day{}
-			weeks = append(weeks, w)
-			lastWeek = w.Number
-		}
-		d.Dinner = r
-		d.Date, err = time.Parse("2006-01-02", weekDateStr)
-		if err != nil {
-			return nil, err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM household_member WHERE user_id = ?",
+		"DELETE FROM household_invite WHERE user_id = ?",
+		"DELETE FROM session WHERE user_id = ?",
+		"DELETE FROM api_token WHERE user_id = ?",
+		"DELETE FROM planner_settings WHERE user_id = ?",
+		"DELETE FROM dietary_profile WHERE user_id = ?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
 		}
-		days = append(days, d)
 	}
+	result, err := tx.Exec("DELETE FROM user WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return fmt.Errorf("failed to delete user: %w", sql.ErrNoRows)
+	}
+	return tx.Commit()
+}
 
-	return weeks, nil
+// deleteHousehold deletes the household with everything it owns.
+func deleteHousehold(tx *sql.Tx, householdID string) error {
+	for _, query := range []string{
+		"DELETE FROM recipes_items WHERE recipe_id IN (SELECT id FROM recipe WHERE household_id = ?)",
+		"DELETE FROM recipe_tag WHERE recipe_id IN (SELECT id FROM recipe WHERE household_id = ?)",
+		"DELETE FROM meal WHERE week_id IN (SELECT id FROM week WHERE household_id = ?)",
+		"DELETE FROM day WHERE week_id IN (SELECT id FROM week WHERE household_id = ?)",
+		"DELETE FROM recipe WHERE household_id = ?",
+		"DELETE FROM week WHERE household_id = ?",
+		"DELETE FROM pantry WHERE household_id = ?",
+		"DELETE FROM household_invite WHERE household_id = ?",
+		"DELETE FROM household WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, householdID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (us *UserService) ValidateUserToken(token string) (string, error) {
-	var resToken string
-	err := us.db.QueryRow("SELECT token FROM user WHERE token = ?", token).Scan(&resToken)
+// UpdateUser changes the name and username of the user, those that are
+// set in update. Returns app.ErrUsernameTaken if another user has the
+// username.
+func (u *UserService) UpdateUser(id string, update *app.UserUpdate) (*app.User, error) {
+	user, err := u.User(id)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if update.Name != nil {
+		user.Name = *update.Name
 	}
-	if resToken == "" {
-		return "", fmt.Errorf("token is empty or not set")
+	if update.Username != nil {
+		user.Username = *update.Username
 	}
-	return resToken, nil
+	_, err = u.db.Exec("UPDATE user SET name = ?, username = ? WHERE id = ?", user.Name, user.Username, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, app.ErrUsernameTaken
+		}
+		return nil, err
+	}
+	return user, nil
 }
 
-func (us *UserService) SaveUserToken(id string, token string) error {
-	tx, err := us.db.Begin()
+// SetUserPassword replaces the password hash of the user and ends every
+// session of theirs but keepSessionID, so that a changed password locks
+// out whoever knew the old one.
+func (u *UserService) SetUserPassword(id string, hash []byte, keepSessionID string) error {
+	tx, err := u.db.Begin()
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare("UPDATE user SET token = ? WHERE id = ?")
+	defer tx.Rollback()
+	result, err := tx.Exec("UPDATE user SET hash = ? WHERE id = ?", string(hash), id)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-
-	if _, err := stmt.Exec(token, id); err != nil {
+	if rows, err := result.RowsAffected(); err != nil {
 		return err
+	} else if rows == 0 {
+		return sql.ErrNoRows
 	}
-	tx.Commit()
-	return nil
+	if _, err := tx.Exec("DELETE FROM session WHERE user_id = ? AND id != ?", id, keepSessionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM api_token WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (us *UserService) GetUserHash(username string) ([]byte, error) {
@@ -234,7 +224,7 @@ func (us *UserService) GetUserHash(username string) ([]byte, error) {
 func (us *UserService) UserByUserName(username string) (*app.User, error) {
 	var idStr string
 	var user app.User
-	if err := us.db.QueryRow("SELECT id, name, username FROM user WHERE username = ?", username).Scan(&idStr, &user.Name, &user.Username); err != nil {
+	if err := us.db.QueryRow("SELECT id, name, username, role FROM user WHERE username = ?", username).Scan(&idStr, &user.Name, &user.Username, &user.Role); err != nil {
 		return nil, err
 	}
 	var err error
@@ -244,11 +234,47 @@ func (us *UserService) UserByUserName(username string) (*app.User, error) {
 	}
 	return &user, nil
 }
-func (us *UserService) GetUserToken(user string) (string, error) {
-	var token string
-	err := us.db.QueryRow("SELECT token FROM user WHERE username = ?", user).Scan(&token)
+func (us *UserService) PlannerSettings(userID string) (*app.PlannerSettings, error) {
+	settings := app.DefaultPlannerSettings()
+	var slots string
+	err := us.db.QueryRow(`SELECT
+		decay_curve, decay_horizon_days, household_portions, meal_slots, weekly_budget
+	FROM planner_settings
+	WHERE user_id = ?`, userID).Scan(&settings.Curve, &settings.HorizonDays, &settings.HouseholdPortions, &slots, &settings.WeeklyBudget)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mealSlots := splitSlots(slots); len(mealSlots) > 0 {
+		settings.MealSlots = mealSlots
+	}
+	return settings, nil
+}
+
+func (us *UserService) SavePlannerSettings(userID string, settings *app.PlannerSettings) error {
+	_, err := us.db.Exec(`INSERT INTO planner_settings(
+		user_id, decay_curve, decay_horizon_days, household_portions, meal_slots, weekly_budget
+	)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		decay_curve = excluded.decay_curve,
+		decay_horizon_days = excluded.decay_horizon_days,
+		household_portions = excluded.household_portions,
+		meal_slots = excluded.meal_slots,
+		weekly_budget = excluded.weekly_budget
+	`, userID, settings.Curve, settings.HorizonDays, settings.HouseholdPortions, joinSlots(settings.MealSlots), settings.WeeklyBudget)
+	return err
+}
+
+func (us *UserService) SetUserRole(userID string, role app.UserRole) error {
+	res, err := us.db.Exec("UPDATE user SET role = ? WHERE id = ?", role, userID)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return token, nil
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("failed to set role: user %s not found", userID)
+	}
+	return nil
 }
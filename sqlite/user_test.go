@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"testing"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func TestSetUserPasswordEndsSessionsAndTokens(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	us := NewUserService(db)
+	user, err := us.CreateUser(&app.NewUser{Name: "Ann", Username: "ann"}, []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := user.ID.String()
+	kept, err := us.CreateSession(id, "kept", "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.CreateSession(id, "other", "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.CreateAPIToken(id, &app.NewAPIToken{Name: "script", Scopes: []app.Scope{app.ScopeShoppingListRead}}, "msp_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := us.SetUserPassword(id, []byte("new hash"), kept.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.ValidateUserToken("kept"); err != nil {
+		t.Errorf("Expected the kept session to stay valid, got %v", err)
+	}
+	if _, err := us.ValidateUserToken("other"); err == nil {
+		t.Error("Expected the other session to end")
+	}
+	if _, err := us.ValidateAPIToken("msp_token"); err == nil {
+		t.Error("Expected the API token to be revoked")
+	}
+	if tokens, err := us.APITokens(id); err != nil || len(tokens) != 0 {
+		t.Errorf("Expected no API tokens left, got %d, %v", len(tokens), err)
+	}
+}
@@ -2,239 +2,262 @@ package sqlite
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/snabb/isoweek"
 
 	"nrdev.se/mealshuffler/app"
 )
 
 type WeekService struct {
 	db *sql.DB
+	// recipes looks up the recipes planned for days.
+	recipes *RecipeService
 }
 
 func NewWeekService(db *sql.DB) *WeekService {
-	ws := &WeekService{db: db}
-	err := ws.CreateWeekTable()
-	if err != nil {
-		panic(err)
-	}
-	return ws
+	return &WeekService{db: db, recipes: &RecipeService{db: db}}
 }
 
-func (ws *WeekService) CreateWeekTable() error {
-	query := (`
-		CREATE TABLE IF NOT EXISTS week (
-			id TEXT PRIMARY KEY,
-			days TEXT,
-			number INTEGER NOT NULL,
-			year INTEGER NOT NULL,
-			user_id TEXT NOT NULL
-		);
-	`)
-	if _, err := ws.db.Exec(query); err != nil {
-		return err
-	}
-	return nil
-}
+const weekColumns = "id, number, year, seed, status, name"
 
-func (ws *WeekService) Week(id string, userID string) (*app.Week, error) {
-	query := (`
-		SELECT id, days, number
-		FROM week
-		WHERE id = ? AND user_id = ?
-	`)
-	row := ws.db.QueryRow(query, id, userID)
+// week returns the week found by query with its days.
+func (ws *WeekService) week(householdID string, query string, args ...any) (*app.Week, error) {
 	var week app.Week
-	var daysJSON string
-	err := row.Scan(&week.ID, &daysJSON, &week.Number)
+	err := ws.db.QueryRow(query, args...).Scan(&week.ID, &week.Number, &week.Year, &week.Seed, &week.Status, &week.Name)
 	if err != nil {
 		return nil, err
 	}
-	var days []*app.Day
-	err = json.Unmarshal([]byte(daysJSON), &days)
-	if err != nil {
+	if err := ws.loadDays(householdID, &week); err != nil {
 		return nil, err
 	}
-	week.Days = days
 	return &week, nil
 }
 
-func (ws *WeekService) Weeks(userID string, year int) ([]*app.Week, error) {
-	query := (`
-		SELECT DISTINCT id, days, number, year
-		FROM week
-		WHERE user_id = ? AND year = ? and number != -1
-	`)
-	rows, err := ws.db.Query(query, userID, year)
+// weeks returns the weeks found by query with their days.
+func (ws *WeekService) weeks(householdID string, query string, args ...any) ([]*app.Week, error) {
+	rows, err := ws.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	weeks := []*app.Week{}
 	for rows.Next() {
-		week := &app.Week{}
-		var daysJSON string
-		err := rows.Scan(&week.ID, &daysJSON, &week.Number, &week.Year)
-		if err != nil {
-			return nil, err
-		}
-		var days []*app.Day
-		err = json.Unmarshal([]byte(daysJSON), &days)
-		if err != nil {
+		var week app.Week
+		if err := rows.Scan(&week.ID, &week.Number, &week.Year, &week.Seed, &week.Status, &week.Name); err != nil {
 			return nil, err
 		}
-		week.Days = days
-		weeks = append(weeks, week)
+		weeks = append(weeks, &week)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if err := ws.loadDays(householdID, weeks...); err != nil {
+		return nil, err
 	}
 	return weeks, nil
 }
 
-func (ws *WeekService) CreateWeek(newWeek *app.NewWeek, userID string) (*app.Week, error) {
-	id := uuid.New()
+func (ws *WeekService) Week(id string, householdID string) (*app.Week, error) {
 	query := (`
-		INSERT INTO week (id, days, number, year, user_id)
-		VALUES (?, ?, ?, ?, ?)
+		SELECT ` + weekColumns + `
+		FROM week
+		WHERE id = ? AND household_id = ?
 	`)
-	daysJSON, err := json.Marshal(newWeek.Days)
-	if err != nil {
-		return nil, err
+	return ws.week(householdID, query, id, householdID)
+}
+
+func (ws *WeekService) Weeks(householdID string, year int) ([]*app.Week, error) {
+	return ws.weeksWithStatus(householdID, year, app.WeekAccepted)
+}
+
+func (ws *WeekService) ArchivedWeeks(householdID string, year int) ([]*app.Week, error) {
+	return ws.weeksWithStatus(householdID, year, app.WeekArchived)
+}
+
+func (ws *WeekService) weeksWithStatus(householdID string, year int, status app.WeekStatus) ([]*app.Week, error) {
+	query := (`
+		SELECT ` + weekColumns + `
+		FROM week
+		WHERE household_id = ? AND year = ? AND status = ?
+		ORDER BY number
+	`)
+	return ws.weeks(householdID, query, householdID, year, status)
+}
+
+// weekStatus returns the status a new week is stored with.
+func weekStatus(week *app.NewWeek) app.WeekStatus {
+	if week.Status == "" {
+		return app.WeekAccepted
 	}
-	tx, err := ws.db.Begin()
+	return week.Status
+}
+
+func (ws *WeekService) CreateWeek(newWeek *app.NewWeek, householdID string) (*app.Week, error) {
+	weeks, err := ws.CreateWeeks([]*app.NewWeek{newWeek}, householdID)
 	if err != nil {
 		return nil, err
 	}
-	stmt, err := tx.Prepare(query)
+	return weeks[0], nil
+}
+
+func (ws *WeekService) UpdateWeek(week *app.Week, householdID string) (*app.Week, error) {
+	weeks, err := ws.UpdateWeeks([]*app.Week{week}, householdID)
 	if err != nil {
 		return nil, err
 	}
-	_, err = stmt.Exec(id, daysJSON, newWeek.Number, newWeek.Year, userID)
+	return weeks[0], nil
+}
+
+func (ws *WeekService) UpdateWeeks(weeks []*app.Week, householdID string) ([]*app.Week, error) {
+	tx, err := ws.db.Begin()
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	for _, week := range weeks {
+		if err := updateWeek(tx, week, householdID, now); err != nil {
+			return nil, err
+		}
+	}
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
-	return &app.Week{
-		Entity: app.Entity{
-			ID: id,
-		},
-		NewWeek: *newWeek,
-	}, nil
+
+	return weeks, nil
 }
 
-func (ws *WeekService) UpdateWeek(week *app.Week, userID string) (*app.Week, error) {
-	query := (`
-		UPDATE week
-		SET days = ?, number = ?, year = ?
-		WHERE id = ? AND user_id = ?
-	`)
-	daysJSON, err := json.Marshal(week.Days)
-	if err != nil {
-		return nil, err
-	}
-	tx, err := ws.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return nil, err
+// updateWeek replaces the seed and days of the week. Accepted weeks keep
+// their iso week, so that no two are accepted for the same one, and
+// archived weeks are left as they are.
+func updateWeek(tx *sql.Tx, week *app.Week, householdID string, now time.Time) error {
+	var status app.WeekStatus
+	var number, year int
+	err := tx.QueryRow("SELECT status, number, year FROM week WHERE id = ? AND household_id = ?",
+		week.ID, householdID).Scan(&status, &number, &year)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("week %s not found: %w", week.ID, err)
 	}
-	res, err := stmt.Exec(daysJSON, week.Number, week.Year, week.ID, userID)
 	if err != nil {
-		tx.Rollback()
-		return nil, err
+		return err
 	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		tx.Rollback()
-		return nil, fmt.Errorf("week not found: got %d rows affected", rowsAffected)
+	switch status {
+	case app.WeekArchived:
+		return app.ErrWeekArchived
+	case app.WeekAccepted:
+		week.Number, week.Year = number, year
 	}
-	err = tx.Commit()
+	week.Status = status
+	_, err = tx.Exec(`
+		UPDATE week
+		SET number = ?, year = ?, seed = ?, updated_at = ?
+		WHERE id = ? AND household_id = ?
+	`, week.Number, week.Year, week.Seed, now, week.ID, householdID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return week, nil
+	return saveDays(tx, week.ID, householdID, week.Days)
 }
 
-func (ws *WeekService) UpdateWeeks(weeks []*app.Week, userID string) ([]*app.Week, error) {
-	query := (`
-		UPDATE week
-		SET days = ?, number = ?, year = ?
-		WHERE id = ? AND user_id = ?
-	`)
+func (ws *WeekService) CreateWeeks(newWeeks []*app.NewWeek, householdID string) ([]*app.Week, error) {
 	tx, err := ws.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	stmt, err := tx.Prepare(query)
+	defer tx.Rollback()
+	weeks, err := insertWeeks(tx, newWeeks, householdID)
 	if err != nil {
 		return nil, err
 	}
-	for _, week := range weeks {
-		daysJSON, err := json.Marshal(week.Days)
-		if err != nil {
-			return nil, err
-		}
-		res, err := stmt.Exec(daysJSON, week.Number, week.Year, week.ID, userID)
-		if err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-		rowsAffected, err := res.RowsAffected()
-		if err != nil || rowsAffected == 0 {
-			tx.Rollback()
-			return nil, fmt.Errorf("week not found")
-		}
-	}
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
-
 	return weeks, nil
 }
 
-func (ws *WeekService) CreateWeeks(newWeeks []*app.NewWeek, userID string) ([]*app.Week, error) {
-	weeks := []*app.Week{}
-	tx, err := ws.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-	query := (`
-		INSERT INTO week (id, days, number, year, user_id)
-		VALUES (?, ?, ?, ?, ?)
+// insertWeeks stores the new weeks. An accepted week replaces the one
+// accepted for the same iso week before, which is archived.
+func insertWeeks(tx *sql.Tx, newWeeks []*app.NewWeek, householdID string) ([]*app.Week, error) {
+	// user_id has no default in databases from before households.
+	stmt, err := tx.Prepare(`
+		INSERT INTO week (id, number, year, user_id, household_id, seed, status, name, updated_at)
+		VALUES (?, ?, ?, '', ?, ?, ?, ?, ?)
 	`)
-	stmt, err := tx.Prepare(query)
 	if err != nil {
 		return nil, err
 	}
+	defer stmt.Close()
+	now := time.Now().UTC()
+	weeks := []*app.Week{}
 	for _, newWeek := range newWeeks {
-		var week *app.Week
-		var daysJSON []byte
-		daysJSON, err = json.Marshal(newWeek.Days)
 		id := uuid.New()
+		status := weekStatus(newWeek)
+		if status == app.WeekAccepted {
+			_, err := tx.Exec(`
+				UPDATE week
+				SET status = ?, updated_at = ?
+				WHERE household_id = ? AND year = ? AND number = ? AND status = ?
+			`, app.WeekArchived, now, householdID, newWeek.Year, newWeek.Number, app.WeekAccepted)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = stmt.Exec(id, newWeek.Number, newWeek.Year, householdID, newWeek.Seed, status, newWeek.Name, now)
 		if err != nil {
 			return nil, err
 		}
-		_, err = stmt.Exec(id, string(daysJSON), newWeek.Number, newWeek.Year, userID)
-		if err != nil {
+		if err := saveDays(tx, id, householdID, newWeek.Days); err != nil {
 			return nil, err
 		}
-		week = &app.Week{
+		week := &app.Week{
 			Entity: app.Entity{
 				ID: id,
 			},
 			NewWeek: *newWeek,
 		}
+		week.Status = status
 		weeks = append(weeks, week)
 	}
+	return weeks, nil
+}
+
+func (ws *WeekService) AcceptDraft(draftID string, newWeeks []*app.NewWeek, householdID string) ([]*app.Week, error) {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := deleteDays(tx, "id = ? AND household_id = ? AND status = ?", draftID, householdID, app.WeekDraft); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`
+		DELETE FROM week
+		WHERE id = ? AND household_id = ? AND status = ?
+	`, draftID, householdID, app.WeekDraft)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return nil, fmt.Errorf("draft not found")
+	}
+	accepted := make([]*app.NewWeek, len(newWeeks))
+	for i, newWeek := range newWeeks {
+		week := *newWeek
+		week.Status = app.WeekAccepted
+		week.Name = ""
+		accepted[i] = &week
+	}
+	weeks, err := insertWeeks(tx, accepted, householdID)
+	if err != nil {
+		return nil, err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -242,44 +265,58 @@ func (ws *WeekService) CreateWeeks(newWeeks []*app.NewWeek, userID string) ([]*a
 	return weeks, nil
 }
 
-func (ws *WeekService) LastGeneratedWeek(userID string) (*app.Week, error) {
+func (ws *WeekService) Drafts(householdID string) ([]*app.Week, error) {
 	query := (`
-		SELECT id, days, number, year
+		SELECT ` + weekColumns + `
 		FROM week
-		WHERE number = -1 AND user_id = ?
+		WHERE status = ? AND household_id = ?
+		ORDER BY updated_at DESC
 	`)
-	row := ws.db.QueryRow(query, userID)
-	var week app.Week
-	var daysJSON string
-	err := row.Scan(&week.ID, &daysJSON, &week.Number, &week.Year)
-	if err != nil {
-		return nil, err
-	}
-	var days []*app.Day
-	err = json.Unmarshal([]byte(daysJSON), &days)
-	if err != nil {
-		return nil, err
-	}
-	week.Days = days
-	return &week, nil
+	return ws.weeks(householdID, query, app.WeekDraft, householdID)
+}
+
+func (ws *WeekService) Draft(name string, householdID string) (*app.Week, error) {
+	query := (`
+		SELECT ` + weekColumns + `
+		FROM week
+		WHERE status = ? AND name = ? AND household_id = ?
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`)
+	return ws.week(householdID, query, app.WeekDraft, name, householdID)
+}
+
+func (ws *WeekService) LastGeneratedWeek(householdID string) (*app.Week, error) {
+	query := (`
+		SELECT ` + weekColumns + `
+		FROM week
+		WHERE status = ? AND household_id = ?
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`)
+	return ws.week(householdID, query, app.WeekDraft, householdID)
 }
 
-func (ws *WeekService) DeleteWeeks(userID string, year int) error {
+func (ws *WeekService) DeleteWeeks(householdID string, year int) error {
 	query := (`
 		DELETE FROM week
-		WHERE user_id = ? AND year = ?
+		WHERE household_id = ? AND year = ? AND status != ?
 	`)
 	tx, err := ws.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+	if err := deleteDays(tx, "household_id = ? AND year = ? AND status != ?", householdID, year, app.WeekDraft); err != nil {
+		return err
+	}
 	stmt, err := tx.Prepare(query)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(userID, year)
+	defer stmt.Close()
+	_, err = stmt.Exec(householdID, year, app.WeekDraft)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 	err = tx.Commit()
@@ -289,22 +326,26 @@ func (ws *WeekService) DeleteWeeks(userID string, year int) error {
 	return nil
 }
 
-func (ws *WeekService) DeleteWeek(id string, userID string) error {
+func (ws *WeekService) DeleteWeek(id string, householdID string) error {
 	query := (`
 		DELETE FROM week
-		WHERE id = ? AND user_id = ?
+		WHERE id = ? AND household_id = ?
 	`)
 	tx, err := ws.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+	if err := deleteDays(tx, "id = ? AND household_id = ?", id, householdID); err != nil {
+		return err
+	}
 	stmt, err := tx.Prepare(query)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(id, userID)
+	defer stmt.Close()
+	_, err = stmt.Exec(id, householdID)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 	err = tx.Commit()
@@ -314,17 +355,24 @@ func (ws *WeekService) DeleteWeek(id string, userID string) error {
 	return nil
 }
 
-func (ws *WeekService) NextWeekNumber(userID string) (int, error) {
+func (ws *WeekService) NextWeek(householdID string, today time.Time) (int, int, error) {
 	query := (`
-		SELECT MAX(number)
+		SELECT year, number
 		FROM week
-		WHERE user_id = ?
+		WHERE household_id = ? AND status = ?
+		ORDER BY year DESC, number DESC
+		LIMIT 1
 	`)
-	row := ws.db.QueryRow(query, userID)
-	var number int
-	err := row.Scan(&number)
+	row := ws.db.QueryRow(query, householdID, app.WeekAccepted)
+	var year, number int
+	err := row.Scan(&year, &number)
+	if err == sql.ErrNoRows {
+		year, number = today.ISOWeek()
+		return year, number, nil
+	}
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	return number + 1, nil
+	year, number = isoweek.StartTime(year, number, time.UTC).AddDate(0, 0, 7).ISOWeek()
+	return year, number, nil
 }
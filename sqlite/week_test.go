@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nrdev.se/mealshuffler/app"
+)
+
+func TestCreateWeeksArchivesTheWeekItReplaces(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	ws := NewWeekService(rs.db)
+	first, err := ws.CreateWeek(&app.NewWeek{Number: 5, Year: 2024}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.CreateWeek(&app.NewWeek{Number: 5, Year: 2024, Status: app.WeekDraft, Name: "next"}, householdID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := ws.CreateWeek(&app.NewWeek{Number: 5, Year: 2024, Status: app.WeekAccepted}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weeks, err := ws.Weeks(householdID, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weeks) != 1 || weeks[0].ID != second.ID {
+		t.Errorf("Expected only the second week to be accepted, got %d", len(weeks))
+	}
+	archived, err := ws.ArchivedWeeks(householdID, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 || archived[0].ID != first.ID {
+		t.Errorf("Expected the first week to be archived, got %d", len(archived))
+	}
+	if drafts, err := ws.Drafts(householdID); err != nil || len(drafts) != 1 {
+		t.Errorf("Expected the draft to be left alone, got %d, %v", len(drafts), err)
+	}
+}
+
+func TestUpdateWeekKeepsTheIsoWeekOfAcceptedWeeks(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	ws := NewWeekService(rs.db)
+	recipe, err := rs.CreateRecipe(&app.NewRecipe{Name: "Soup", Portions: 4, ProbabilityWeight: 1}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := &app.Day{}
+	day.SetRecipe(app.MealDinner, recipe)
+	for _, number := range []int{5, 6} {
+		if _, err := ws.CreateWeek(&app.NewWeek{Number: number, Year: 2024}, householdID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	draft, err := ws.CreateWeek(&app.NewWeek{Number: 7, Year: 2024, Status: app.WeekDraft}, householdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted, err := ws.Weeks(householdID, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved := *accepted[0]
+	moved.Number, moved.Days = 6, []*app.Day{day}
+	if _, err := ws.UpdateWeek(&moved, householdID); err != nil {
+		t.Fatal(err)
+	}
+	weeks, err := ws.Weeks(householdID, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weeks) != 2 || weeks[0].Number != 5 || weeks[1].Number != 6 || len(weeks[0].Days) != 1 {
+		t.Errorf("Expected the accepted week to keep week 5 with the new days, got %d weeks", len(weeks))
+	}
+
+	draft.Number, draft.Year = 1, 2025
+	if updated, err := ws.UpdateWeek(draft, householdID); err != nil || updated.Number != 1 || updated.Status != app.WeekDraft {
+		t.Errorf("Expected the draft to move to week 1 of 2025, got %+v, %v", updated, err)
+	}
+
+	if _, err := ws.CreateWeek(&app.NewWeek{Number: 5, Year: 2024}, householdID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.UpdateWeek(&moved, householdID); !errors.Is(err, app.ErrWeekArchived) {
+		t.Errorf("Expected ErrWeekArchived updating an archived week, got %v", err)
+	}
+	if _, err := ws.UpdateWeek(&app.Week{Entity: app.Entity{ID: uuid.New()}}, householdID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected an unknown week not to be found, got %v", err)
+	}
+}
+
+func TestNextWeekCrossesTheYear(t *testing.T) {
+	rs, householdID := migratedDB(t)
+	ws := NewWeekService(rs.db)
+	today := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	if year, number, err := ws.NextWeek(householdID, today); err != nil || year != 2026 || number != 42 {
+		t.Errorf("Expected the week of today without accepted weeks, got %d-%d, %v", year, number, err)
+	}
+
+	tests := []struct {
+		year, number         int
+		nextYear, nextNumber int
+	}{
+		{2024, 30, 2024, 31},
+		{2025, 52, 2026, 1},
+		{2026, 53, 2027, 1},
+	}
+	for _, tt := range tests {
+		if _, err := ws.CreateWeek(&app.NewWeek{Number: tt.number, Year: tt.year}, householdID); err != nil {
+			t.Fatal(err)
+		}
+		year, number, err := ws.NextWeek(householdID, today)
+		if err != nil || year != tt.nextYear || number != tt.nextNumber {
+			t.Errorf("Expected %d-%d after %d-%d, got %d-%d, %v", tt.nextYear, tt.nextNumber, tt.year, tt.number, year, number, err)
+		}
+	}
+	if _, err := ws.CreateWeek(&app.NewWeek{Number: 40, Year: 2025}, householdID); err != nil {
+		t.Fatal(err)
+	}
+	if year, number, err := ws.NextWeek(householdID, today); err != nil || year != 2027 || number != 1 {
+		t.Errorf("Expected an earlier year not to matter, got %d-%d, %v", year, number, err)
+	}
+}